@@ -0,0 +1,84 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+// staticIPNameKey names a pre-reserved GCE global static IP that takes the
+// place of the one the controller would otherwise reserve for an Ingress.
+// The referenced address must already exist; the controller only checks that
+// it does. The forwarding rule is created without an address, so it does not
+// use this IP.
+const staticIPNameKey = "kubernetes.io/ingress.global-static-ip-name"
+
+// staticIPName returns the user-provided static IP name for ing, or "" if
+// the Ingress doesn't request one.
+func staticIPName(ing *extensions.Ingress) string {
+	return ing.Annotations[staticIPNameKey]
+}
+
+// ingressKey returns the identifier used to name every GCE resource backing
+// a single Ingress' L7 loadbalancer.
+func ingressKey(ing *extensions.Ingress) string {
+	return fmt.Sprintf("%v-%v", ing.Namespace, ing.Name)
+}
+
+// serviceAppProtocolsKey is the annotation used to declare the wire protocol
+// a Service's named port speaks, eg:
+//   ingress.kubernetes.io/app-protocols: '{"https":"HTTPS"}'
+// Ports not mentioned default to HTTP.
+const serviceAppProtocolsKey = "ingress.kubernetes.io/app-protocols"
+
+// serviceAppProtocols unmarshals the serviceAppProtocolsKey annotation into
+// a map of port name to protocol ("HTTP" or "HTTPS").
+func serviceAppProtocols(svc *api.Service) (map[string]string, error) {
+	val, ok := svc.Annotations[serviceAppProtocolsKey]
+	if !ok {
+		return map[string]string{}, nil
+	}
+	protocols := map[string]string{}
+	if err := json.Unmarshal([]byte(val), &protocols); err != nil {
+		return nil, fmt.Errorf("failed to parse %v annotation on Service %v/%v: %v", serviceAppProtocolsKey, svc.Namespace, svc.Name, err)
+	}
+	for name, protocol := range protocols {
+		if protocol != "HTTP" && protocol != "HTTPS" {
+			return nil, fmt.Errorf("unsupported protocol %q for port %q in %v annotation on Service %v/%v", protocol, name, serviceAppProtocolsKey, svc.Namespace, svc.Name)
+		}
+	}
+	return protocols, nil
+}
+
+// toServicePort resolves the ServicePort (NodePort + protocol) for the given
+// Service port, consulting serviceAppProtocolsKey for the protocol and
+// defaulting to HTTP when the port isn't mentioned.
+func toServicePort(svc *api.Service, sp api.ServicePort) (ServicePort, error) {
+	protocols, err := serviceAppProtocols(svc)
+	if err != nil {
+		return ServicePort{}, err
+	}
+	protocol := protocols[sp.Name]
+	if protocol == "" {
+		protocol = "HTTP"
+	}
+	return ServicePort{Port: int64(sp.NodePort), Protocol: protocol}, nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	compute "google.golang.org/api/compute/v1"
+
+	"github.com/golang/glog"
+)
+
+// Backends implements BackendPool, mapping each Kubernetes Service NodePort
+// to a GCE backend service fronted by a health check on the same port, all
+// owned by the cluster's single shared HealthChecker.
+type Backends struct {
+	cloud         BackendServices
+	nodePool      NodePool
+	healthChecker HealthChecker
+	namer         *Namer
+	// knownPorts tracks every port this pool has created a backend for, so
+	// GC can reap ones no longer in use without a BackendServices.List.
+	// Unlike L7s.GC (which lists every url map in the project to recover
+	// loadbalancers a restarted controller never re-Added), BackendServices
+	// has no List method, so a NodePort whose Ingress is deleted while the
+	// controller isn't running is never reaped: knownPorts starts empty on
+	// restart and only regains an entry once something Syncs that port again.
+	knownPorts map[int64]bool
+}
+
+// NewBackendPool returns a new backend pool.
+// cloud: implements BackendServices.
+// healthChecker: the cluster's single HealthChecker, used to create/delete
+//	the health check backing every NodePort (including the default backend).
+// nodePool: implements NodePool, used to look up the instance group that
+//	backs every backend.
+// namer: is used to tag GCE resources with a cluster wide identifier.
+func NewBackendPool(cloud BackendServices, healthChecker HealthChecker, nodePool NodePool, namer *Namer) *Backends {
+	return &Backends{
+		cloud:         cloud,
+		nodePool:      nodePool,
+		healthChecker: healthChecker,
+		namer:         namer,
+		knownPorts:    map[int64]bool{},
+	}
+}
+
+// Get returns a single backend.
+func (b *Backends) Get(port int64) (*compute.BackendService, error) {
+	be, err := b.cloud.GetBackendService(b.namer.BeName(port))
+	if err != nil {
+		return nil, err
+	}
+	return be, nil
+}
+
+// Add will create a backend for the given ServicePort if one doesn't
+// already exist, along with a health check derived from the backing Pods'
+// readiness probe (see HealthChecker.Add). Ports annotated HTTPS get an
+// HTTPS backend service paired with an HttpsHealthCheck.
+func (b *Backends) Add(p ServicePort) error {
+	if err := b.healthChecker.Add(p); err != nil {
+		return err
+	}
+	b.knownPorts[p.Port] = true
+	pName := b.namer.BeName(p.Port)
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = "HTTP"
+	}
+	be, _ := b.Get(p.Port)
+	if be != nil {
+		if be.Protocol == protocol {
+			return nil
+		}
+		glog.Infof("Updating backend service %v protocol %v -> %v", pName, be.Protocol, protocol)
+		be.Protocol = protocol
+		hcLink, err := b.healthChecker.SelfLink(p.Port)
+		if err != nil {
+			return err
+		}
+		be.HealthChecks = []string{hcLink}
+		return b.cloud.UpdateBackendService(be)
+	}
+	hcLink, err := b.healthChecker.SelfLink(p.Port)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Creating backend service for port %v named %v", p.Port, pName)
+	be = &compute.BackendService{
+		Name:         pName,
+		Protocol:     protocol,
+		HealthChecks: []string{hcLink},
+		Port:         p.Port,
+		PortName:     fmt.Sprintf("port%v", p.Port),
+	}
+	ig, err := b.nodePool.Get(b.namer.IGName())
+	if err != nil {
+		// The instance group doesn't exist until the first node sync, which
+		// may not have happened yet (eg the default backend); create it empty.
+		if err := b.nodePool.Add(nil); err != nil {
+			return err
+		}
+		if ig, err = b.nodePool.Get(b.namer.IGName()); err != nil {
+			return err
+		}
+	}
+	be.Backends = []*compute.Backend{{Group: ig.SelfLink}}
+	return b.cloud.CreateBackendService(be)
+}
+
+// Delete deletes the backend for the given port.
+func (b *Backends) Delete(port int64) error {
+	name := b.namer.BeName(port)
+	glog.Infof("Deleting backend service %v", name)
+	delete(b.knownPorts, port)
+	if err := b.cloud.DeleteBackendService(name); err != nil {
+		return err
+	}
+	return b.healthChecker.Delete(port)
+}
+
+// Sync syncs backend services corresponding to the given ServicePorts.
+func (b *Backends) Sync(svcPorts []ServicePort) error {
+	glog.Infof("Syncing backends %v", svcPorts)
+	for _, p := range svcPorts {
+		if err := b.Add(p); err != nil {
+			return err
+		}
+	}
+	return b.healthChecker.Sync(portList(svcPorts))
+}
+
+// GC garbage collects backends (and, transitively, their health checks) not
+// in the given list of ServicePorts. This only covers ports knownPorts has
+// seen this process's lifetime (see its doc comment) — it is not restart-safe
+// the way L7s.GC is.
+func (b *Backends) GC(svcPorts []ServicePort) error {
+	knownPorts := map[int64]bool{}
+	for _, p := range svcPorts {
+		knownPorts[p.Port] = true
+	}
+	for port := range b.knownPorts {
+		if !knownPorts[port] {
+			if err := b.Delete(port); err != nil {
+				return err
+			}
+		}
+	}
+	return b.healthChecker.GC(portList(svcPorts))
+}
+
+// portList extracts the NodePorts from a list of ServicePorts.
+func portList(svcPorts []ServicePort) []int64 {
+	ports := make([]int64, 0, len(svcPorts))
+	for _, p := range svcPorts {
+		ports = append(ports, p.Port)
+	}
+	return ports
+}
+
+// Shutdown deletes all backends and associated health checks.
+func (b *Backends) Shutdown() error {
+	return b.GC([]ServicePort{})
+}
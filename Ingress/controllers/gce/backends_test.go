@@ -0,0 +1,89 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func newTestBackendPool() (*fakeCloudClient, *Backends) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	healthChecker := NewHealthChecker(cloud, "/", 0, "/", namer)
+	nodePool := NewNodePool(cloud, namer)
+	return cloud, NewBackendPool(cloud, healthChecker, nodePool, namer)
+}
+
+func TestBackendPoolAddCreatesHTTPBackendByDefault(t *testing.T) {
+	_, pool := newTestBackendPool()
+	if err := pool.Add(ServicePort{Port: 80}); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	be, err := pool.Get(80)
+	if err != nil {
+		t.Fatalf("Get(80) = %v", err)
+	}
+	if be.Protocol != "HTTP" {
+		t.Errorf("Protocol = %v, want HTTP", be.Protocol)
+	}
+}
+
+func TestBackendPoolAddUpdatesDriftedProtocol(t *testing.T) {
+	cloud, pool := newTestBackendPool()
+	namer := &Namer{uid: "uid1"}
+	if err := pool.Add(ServicePort{Port: 80, Protocol: "HTTP"}); err != nil {
+		t.Fatalf("Add(HTTP) = %v", err)
+	}
+	if err := pool.Add(ServicePort{Port: 80, Protocol: "HTTPS"}); err != nil {
+		t.Fatalf("Add(HTTPS) = %v", err)
+	}
+	be, err := pool.Get(80)
+	if err != nil {
+		t.Fatalf("Get(80) = %v", err)
+	}
+	if be.Protocol != "HTTPS" {
+		t.Errorf("Protocol = %v, want HTTPS after the annotation toggled", be.Protocol)
+	}
+	hcName := namer.HcName(80)
+	if _, err := cloud.GetHttpHealthCheck(hcName); err == nil {
+		t.Errorf("expected the stale HttpHealthCheck to be deleted once the port switched to HTTPS")
+	}
+	httpsHC, err := cloud.GetHttpsHealthCheck(hcName)
+	if err != nil {
+		t.Fatalf("expected an HttpsHealthCheck for port 80, got %v", err)
+	}
+	if be.HealthChecks[0] != httpsHC.SelfLink {
+		t.Errorf("BackendService.HealthChecks = %v, want [%v]", be.HealthChecks, httpsHC.SelfLink)
+	}
+}
+
+func TestBackendPoolGCDeletesUnknownPorts(t *testing.T) {
+	_, pool := newTestBackendPool()
+	if err := pool.Add(ServicePort{Port: 80}); err != nil {
+		t.Fatalf("Add(80) = %v", err)
+	}
+	if err := pool.Add(ServicePort{Port: 443}); err != nil {
+		t.Fatalf("Add(443) = %v", err)
+	}
+	if err := pool.GC([]ServicePort{{Port: 80}}); err != nil {
+		t.Fatalf("GC() = %v", err)
+	}
+	if _, err := pool.Get(80); err != nil {
+		t.Errorf("expected port 80's backend to survive GC, got %v", err)
+	}
+	if _, err := pool.Get(443); err == nil {
+		t.Errorf("expected port 443's backend to be GC'd")
+	}
+}
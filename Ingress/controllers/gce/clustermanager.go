@@ -0,0 +1,63 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	gce "k8s.io/kubernetes/pkg/cloudprovider/providers/gce"
+)
+
+// ClusterManager manages the cloud resources for a single GCE cluster:
+// backends, instance groups, health checks, L7 loadbalancers and the
+// firewall rule fronting them all. It is the single point through which the
+// loadBalancerController talks to the cloud.
+type ClusterManager struct {
+	ClusterNamer           *Namer
+	defaultBackendNodePort int64
+	instancePool           NodePool
+	backendPool            BackendPool
+	healthChecker          HealthChecker
+	l7Pool                 LoadBalancerPool
+	firewallController     *firewallRulesController
+}
+
+// NewClusterManager creates a cluster manager fronting the given
+// defaultBackendNodePort, reading its cloud client, namer and stop channel
+// from ctx, and defaulting backend health checks to defaultHealthCheckPath
+// when no compatible readiness probe exists. The default backend gets its
+// own defaultBackendHealthCheckPath, since a 404 server rarely answers on
+// the same path as real backends. preSharedCert, if non-empty, names an
+// SslCertificate created out of band by the admin (--tls-pre-shared-cert).
+// nodePortRange is opened, cluster wide, to GCE's health check ranges.
+func NewClusterManager(ctx *ControllerContext, defaultBackendNodePort int64, defaultHealthCheckPath, defaultBackendHealthCheckPath, preSharedCert, nodePortRange string) (*ClusterManager, error) {
+	cm := &ClusterManager{ClusterNamer: ctx.ClusterNamer, defaultBackendNodePort: defaultBackendNodePort}
+	cm.instancePool = NewNodePool(ctx.Cloud, ctx.ClusterNamer)
+	cm.healthChecker = NewHealthChecker(ctx.Cloud, defaultHealthCheckPath, defaultBackendNodePort, defaultBackendHealthCheckPath, ctx.ClusterNamer)
+	cm.backendPool = NewBackendPool(ctx.Cloud, cm.healthChecker, cm.instancePool, ctx.ClusterNamer)
+	cm.l7Pool = NewLoadBalancerPool(ctx.Cloud, ctx.ClusterNamer, preSharedCert)
+	cm.firewallController = NewFirewallRulesController(ctx.Cloud, ctx.ClusterNamer, nodePortRange)
+	if err := cm.backendPool.Add(ServicePort{Port: defaultBackendNodePort, Protocol: "HTTP"}); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// getGCECloud returns a cloud interface usable by all the pools above. It's
+// split out so main can substitute a fakeCloudClient (both satisfy
+// cloudInterface) for --running-in-cluster=false dry-runs.
+func getGCECloud() (*gce.GCECloud, error) {
+	return gce.NewGCECloud(nil)
+}
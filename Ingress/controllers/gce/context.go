@@ -0,0 +1,156 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/client/unversioned/cache"
+	"k8s.io/kubernetes/pkg/controller/framework"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+// cloudInterface is the full set of cloud operations every sub-controller
+// needs. It's satisfied by both a real GCE cloud client and fakeCloudClient.
+type cloudInterface interface {
+	BackendServices
+	InstanceGroups
+	LoadBalancers
+	SingleHealthCheck
+	Firewalls
+}
+
+// ControllerContext holds everything shared across the backend, firewall,
+// node and LB controllers: the kube client, the informers they read from,
+// the cloud client and namer, and the stop channel that shuts them all down
+// together. Splitting this out of ClusterManager means every controller can
+// be constructed (and tested) in isolation, against a ControllerContext
+// built from a fake cloud and client.
+//
+// Ingress, Service and Node changes all feed the same enqueue callback: a
+// sync reconciles complete desired state regardless of which object
+// changed, so there's a single shared work queue rather than one per
+// resource kind (see loadBalancerController.sync).
+type ControllerContext struct {
+	KubeClient   *client.Client
+	Cloud        cloudInterface
+	ClusterNamer *Namer
+	StopCh       chan struct{}
+
+	IngressStore cache.Store
+	ServiceStore cache.Store
+	NodeStore    cache.Store
+	PodLister    cache.StoreToPodLister
+
+	ingressController *framework.Controller
+	serviceController *framework.Controller
+	nodeController    *framework.Controller
+	podController     *framework.Controller
+}
+
+// NewControllerContext creates a ControllerContext and wires up its
+// informers, but doesn't start them; call Run to do that. enqueue is called
+// on every Ingress, Service and Node add/update/delete.
+func NewControllerContext(kubeClient *client.Client, cloud cloudInterface, namer *Namer, resyncPeriod time.Duration, enqueue func()) *ControllerContext {
+	ctx := &ControllerContext{
+		KubeClient:   kubeClient,
+		Cloud:        cloud,
+		ClusterNamer: namer,
+		StopCh:       make(chan struct{}),
+	}
+	handlers := framework.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { enqueue() },
+		UpdateFunc: func(old, cur interface{}) { enqueue() },
+		DeleteFunc: func(obj interface{}) { enqueue() },
+	}
+	ctx.IngressStore, ctx.ingressController = framework.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+				return kubeClient.Extensions().Ingress(api.NamespaceAll).List(opts)
+			},
+			WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+				return kubeClient.Extensions().Ingress(api.NamespaceAll).Watch(opts)
+			},
+		},
+		&extensions.Ingress{},
+		resyncPeriod,
+		handlers,
+	)
+	ctx.ServiceStore, ctx.serviceController = framework.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+				return kubeClient.Services(api.NamespaceAll).List(opts)
+			},
+			WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+				return kubeClient.Services(api.NamespaceAll).Watch(opts)
+			},
+		},
+		&api.Service{},
+		resyncPeriod,
+		handlers,
+	)
+	ctx.NodeStore, ctx.nodeController = framework.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+				return kubeClient.Nodes().List(opts)
+			},
+			WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+				return kubeClient.Nodes().Watch(opts)
+			},
+		},
+		&api.Node{},
+		resyncPeriod,
+		handlers,
+	)
+	// Pods only back GetProbe lookups, made synchronously during a sync; a
+	// Pod churning doesn't need to trigger one of its own.
+	ctx.PodLister.Store, ctx.podController = framework.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts api.ListOptions) (runtime.Object, error) {
+				return kubeClient.Pods(api.NamespaceAll).List(api.ListOptions{LabelSelector: labels.Everything(), FieldSelector: fields.Everything()})
+			},
+			WatchFunc: func(opts api.ListOptions) (watch.Interface, error) {
+				return kubeClient.Pods(api.NamespaceAll).Watch(api.ListOptions{LabelSelector: labels.Everything(), FieldSelector: fields.Everything()})
+			},
+		},
+		&api.Pod{},
+		resyncPeriod,
+		framework.ResourceEventHandlerFuncs{},
+	)
+	return ctx
+}
+
+// Run starts every informer owned by the context. It blocks until StopCh is
+// closed.
+func (ctx *ControllerContext) Run() {
+	go ctx.ingressController.Run(ctx.StopCh)
+	go ctx.serviceController.Run(ctx.StopCh)
+	go ctx.nodeController.Run(ctx.StopCh)
+	go ctx.podController.Run(ctx.StopCh)
+	<-ctx.StopCh
+}
+
+// Stop shuts down every informer and controller sharing this context.
+func (ctx *ControllerContext) Stop() {
+	close(ctx.StopCh)
+}
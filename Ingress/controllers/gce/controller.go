@@ -0,0 +1,215 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/golang/glog"
+)
+
+// loadBalancerController watches the Kubernetes api and reconciles Ingress,
+// Service and Pod state with the cloud resources managed by clusterManager.
+// It shares its informers and stop channel with every other controller
+// through ctx, rather than owning a private copy of each.
+type loadBalancerController struct {
+	ctx            *ControllerContext
+	clusterManager *ClusterManager
+}
+
+// NewLoadBalancerController creates a controller for gce loadbalancers.
+func NewLoadBalancerController(ctx *ControllerContext, clusterManager *ClusterManager) (*loadBalancerController, error) {
+	lbc := &loadBalancerController{
+		ctx:            ctx,
+		clusterManager: clusterManager,
+	}
+	// The clusterManager's health checker consults the controller's Pod
+	// cache to translate readiness probes into GCE health checks.
+	if err := lbc.clusterManager.healthChecker.Init(lbc); err != nil {
+		return nil, err
+	}
+	// The l7Pool resolves kubernetes.io/tls Secrets into SslCertificates
+	// straight from the apiserver.
+	lbc.clusterManager.l7Pool.Init(newAPIServerTLSLoader(ctx.KubeClient))
+	return lbc, nil
+}
+
+// sync reconciles the cloud's state with every Ingress, Service and Node
+// currently in the apiserver. It's triggered by ctx's informers (any
+// Ingress/Service/Node add/update/delete) through the shared task queue, and
+// always reconciles complete desired state rather than a single object.
+func (lbc *loadBalancerController) sync(key string) error {
+	glog.V(3).Infof("Syncing (triggered by %q)", key)
+
+	cm := lbc.clusterManager
+	objs := lbc.ctx.IngressStore.List()
+	ings := make([]*extensions.Ingress, 0, len(objs))
+	lbNames := make([]string, 0, len(objs))
+	svcPorts := []ServicePort{{Port: cm.defaultBackendNodePort, Protocol: "HTTP"}}
+	for _, obj := range objs {
+		ing := obj.(*extensions.Ingress)
+		ings = append(ings, ing)
+		lbNames = append(lbNames, ingressKey(ing))
+		ports, err := lbc.ingressServicePorts(ing)
+		if err != nil {
+			return err
+		}
+		svcPorts = append(svcPorts, ports...)
+	}
+
+	nodeNames, err := lbc.listNodeNames()
+	if err != nil {
+		return err
+	}
+	if err := cm.instancePool.Sync(nodeNames); err != nil {
+		return err
+	}
+	if err := cm.backendPool.Sync(svcPorts); err != nil {
+		return err
+	}
+	defaultBackend, err := cm.backendPool.Get(cm.defaultBackendNodePort)
+	if err != nil {
+		return err
+	}
+	if err := cm.l7Pool.Sync(ings, defaultBackend); err != nil {
+		return err
+	}
+	if err := cm.l7Pool.GC(lbNames); err != nil {
+		return err
+	}
+	return cm.backendPool.GC(svcPorts)
+}
+
+// ingressServicePorts resolves the ServicePort (NodePort + protocol) for
+// every backend (default and per-rule) referenced by ing.
+func (lbc *loadBalancerController) ingressServicePorts(ing *extensions.Ingress) ([]ServicePort, error) {
+	var backends []extensions.IngressBackend
+	if ing.Spec.Backend != nil {
+		backends = append(backends, *ing.Spec.Backend)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			backends = append(backends, p.Backend)
+		}
+	}
+	ports := make([]ServicePort, 0, len(backends))
+	for _, be := range backends {
+		svcPort, err := lbc.backendServicePort(ing.Namespace, be)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, svcPort)
+	}
+	return ports, nil
+}
+
+// backendServicePort resolves a single Ingress backend to the NodePort and
+// protocol of the Service port it names.
+func (lbc *loadBalancerController) backendServicePort(namespace string, be extensions.IngressBackend) (ServicePort, error) {
+	obj, exists, err := lbc.ctx.ServiceStore.GetByKey(fmt.Sprintf("%v/%v", namespace, be.ServiceName))
+	if err != nil {
+		return ServicePort{}, err
+	}
+	if !exists {
+		return ServicePort{}, fmt.Errorf("service %v/%v not found", namespace, be.ServiceName)
+	}
+	svc := obj.(*api.Service)
+	for _, sp := range svc.Spec.Ports {
+		if sp.Port == be.ServicePort.IntValue() || sp.Name == be.ServicePort.StrVal {
+			return toServicePort(svc, sp)
+		}
+	}
+	return ServicePort{}, fmt.Errorf("no port %v on service %v/%v", be.ServicePort, namespace, be.ServiceName)
+}
+
+// listNodeNames returns the name of every Node currently in the apiserver.
+func (lbc *loadBalancerController) listNodeNames() ([]string, error) {
+	objs := lbc.ctx.NodeStore.List()
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		names = append(names, obj.(*api.Node).Name)
+	}
+	return names, nil
+}
+
+// GetProbe implements probeProvider. It returns the readiness probe of a
+// Pod backing the given nodeport, if a single compatible one exists.
+func (lbc *loadBalancerController) GetProbe(nodePort int64) (*api.Probe, error) {
+	services, err := lbc.ctx.KubeClient.Services(api.NamespaceAll).List(api.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		for _, sp := range svc.Spec.Ports {
+			if int64(sp.NodePort) != nodePort {
+				continue
+			}
+			// PodLister's store holds Pods from every namespace, so a
+			// label-only selector could otherwise match a same-labeled Pod
+			// in an unrelated namespace.
+			pods, err := lbc.ctx.PodLister.List(labels.SelectorFromSet(svc.Spec.Selector))
+			if err != nil {
+				return nil, err
+			}
+			var pod *api.Pod
+			for i := range pods {
+				if pods[i].Namespace == svc.Namespace {
+					pod = &pods[i]
+					break
+				}
+			}
+			if pod == nil {
+				return nil, nil
+			}
+			for _, c := range pod.Spec.Containers {
+				if c.ReadinessProbe == nil || c.ReadinessProbe.Handler.HTTPGet == nil {
+					continue
+				}
+				for _, cp := range c.Ports {
+					if isPortCompatible(sp.TargetPort, cp.ContainerPort, cp.Name) {
+						return c.ReadinessProbe, nil
+					}
+				}
+			}
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("no Service found for nodePort %v", nodePort)
+}
+
+// Run starts the controller until Stop is called. The shared informers and
+// task queue worker are started by main, not here; this just blocks until
+// shutdown.
+func (lbc *loadBalancerController) Run() {
+	glog.Infof("Starting loadbalancer controller")
+	<-lbc.ctx.StopCh
+}
+
+// Stop stops the controller, and every other controller sharing its
+// ControllerContext.
+func (lbc *loadBalancerController) Stop() error {
+	lbc.ctx.Stop()
+	return nil
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+// fakeCloudClient is an in-memory implementation of BackendServices,
+// InstanceGroups, SingleHealthCheck, LoadBalancers and Firewalls, used in
+// place of a real cloud client so the controller can run (and be tested)
+// without talking to real GCE — see main's --proxy/--running-in-cluster
+// dry-run selection.
+type fakeCloudClient struct {
+	backendServices    map[string]*compute.BackendService
+	healthChecks       map[string]*compute.HttpHealthCheck
+	httpsHealthChecks  map[string]*compute.HttpsHealthCheck
+	instanceGroups     map[string]*compute.InstanceGroup
+	urlMaps            map[string]*compute.UrlMap
+	targetProxies      map[string]*compute.TargetHttpProxy
+	forwardingRules    map[string]*compute.ForwardingRule
+	globalAddresses    map[string]*compute.Address
+	targetHTTPSProxies map[string]*compute.TargetHttpsProxy
+	sslCertificates    map[string]*compute.SslCertificate
+	firewalls          map[string]*compute.Firewall
+}
+
+// newFakeCloudClient returns a ready to use fakeCloudClient.
+func newFakeCloudClient() *fakeCloudClient {
+	return &fakeCloudClient{
+		backendServices:   map[string]*compute.BackendService{},
+		healthChecks:      map[string]*compute.HttpHealthCheck{},
+		httpsHealthChecks: map[string]*compute.HttpsHealthCheck{},
+		instanceGroups:    map[string]*compute.InstanceGroup{},
+		urlMaps:           map[string]*compute.UrlMap{},
+		targetProxies:     map[string]*compute.TargetHttpProxy{},
+		forwardingRules:    map[string]*compute.ForwardingRule{},
+		globalAddresses:    map[string]*compute.Address{},
+		targetHTTPSProxies: map[string]*compute.TargetHttpsProxy{},
+		sslCertificates:    map[string]*compute.SslCertificate{},
+		firewalls:          map[string]*compute.Firewall{},
+	}
+}
+
+// BackendServices
+
+func (f *fakeCloudClient) GetBackendService(name string) (*compute.BackendService, error) {
+	if be, ok := f.backendServices[name]; ok {
+		return be, nil
+	}
+	return nil, fmt.Errorf("backend service %v not found", name)
+}
+
+func (f *fakeCloudClient) UpdateBackendService(be *compute.BackendService) error {
+	f.backendServices[be.Name] = be
+	return nil
+}
+
+func (f *fakeCloudClient) CreateBackendService(be *compute.BackendService) error {
+	be.SelfLink = be.Name
+	f.backendServices[be.Name] = be
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteBackendService(name string) error {
+	delete(f.backendServices, name)
+	return nil
+}
+
+// SingleHealthCheck
+
+func (f *fakeCloudClient) CreateHttpHealthCheck(hc *compute.HttpHealthCheck) error {
+	hc.SelfLink = hc.Name
+	f.healthChecks[hc.Name] = hc
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteHttpHealthCheck(name string) error {
+	delete(f.healthChecks, name)
+	return nil
+}
+
+func (f *fakeCloudClient) GetHttpHealthCheck(name string) (*compute.HttpHealthCheck, error) {
+	if hc, ok := f.healthChecks[name]; ok {
+		return hc, nil
+	}
+	return nil, fmt.Errorf("health check %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateHttpsHealthCheck(hc *compute.HttpsHealthCheck) error {
+	hc.SelfLink = hc.Name
+	f.httpsHealthChecks[hc.Name] = hc
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteHttpsHealthCheck(name string) error {
+	delete(f.httpsHealthChecks, name)
+	return nil
+}
+
+func (f *fakeCloudClient) GetHttpsHealthCheck(name string) (*compute.HttpsHealthCheck, error) {
+	if hc, ok := f.httpsHealthChecks[name]; ok {
+		return hc, nil
+	}
+	return nil, fmt.Errorf("https health check %v not found", name)
+}
+
+// InstanceGroups
+
+func (f *fakeCloudClient) GetInstanceGroup(name string) (*compute.InstanceGroup, error) {
+	if ig, ok := f.instanceGroups[name]; ok {
+		return ig, nil
+	}
+	return nil, fmt.Errorf("instance group %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateInstanceGroup(name string) (*compute.InstanceGroup, error) {
+	ig := &compute.InstanceGroup{Name: name, SelfLink: name}
+	f.instanceGroups[name] = ig
+	return ig, nil
+}
+
+func (f *fakeCloudClient) DeleteInstanceGroup(name string) error {
+	delete(f.instanceGroups, name)
+	return nil
+}
+
+func (f *fakeCloudClient) ListInstancesInInstanceGroup(name string, state string) (*compute.InstanceGroupsListInstances, error) {
+	return &compute.InstanceGroupsListInstances{}, nil
+}
+
+func (f *fakeCloudClient) AddInstancesToInstanceGroup(name string, instanceNames []string) error {
+	return nil
+}
+
+func (f *fakeCloudClient) RemoveInstancesFromInstanceGroup(name string, instanceNames []string) error {
+	return nil
+}
+
+func (f *fakeCloudClient) AddPortToInstanceGroup(ig *compute.InstanceGroup, port int64) (*compute.NamedPort, error) {
+	np := &compute.NamedPort{Name: fmt.Sprintf("port%v", port), Port: port}
+	ig.NamedPorts = append(ig.NamedPorts, np)
+	return np, nil
+}
+
+// LoadBalancers
+
+func (f *fakeCloudClient) GetGlobalForwardingRule(name string) (*compute.ForwardingRule, error) {
+	if fw, ok := f.forwardingRules[name]; ok {
+		return fw, nil
+	}
+	return nil, fmt.Errorf("forwarding rule %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateGlobalForwardingRule(proxy *compute.TargetHttpProxy, name string, portRange string) (*compute.ForwardingRule, error) {
+	fw := &compute.ForwardingRule{Name: name, Target: proxy.SelfLink, PortRange: portRange, SelfLink: name}
+	f.forwardingRules[name] = fw
+	return fw, nil
+}
+
+func (f *fakeCloudClient) DeleteGlobalForwardingRule(name string) error {
+	delete(f.forwardingRules, name)
+	return nil
+}
+
+func (f *fakeCloudClient) SetProxyForGlobalForwardingRule(fw *compute.ForwardingRule, proxy *compute.TargetHttpProxy) error {
+	fw.Target = proxy.SelfLink
+	return nil
+}
+
+func (f *fakeCloudClient) GetUrlMap(name string) (*compute.UrlMap, error) {
+	if um, ok := f.urlMaps[name]; ok {
+		return um, nil
+	}
+	return nil, fmt.Errorf("url map %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateUrlMap(backend *compute.BackendService, name string) (*compute.UrlMap, error) {
+	um := &compute.UrlMap{Name: name, DefaultService: backend.SelfLink, SelfLink: name}
+	f.urlMaps[name] = um
+	return um, nil
+}
+
+func (f *fakeCloudClient) UpdateUrlMap(urlMap *compute.UrlMap) (*compute.UrlMap, error) {
+	f.urlMaps[urlMap.Name] = urlMap
+	return urlMap, nil
+}
+
+func (f *fakeCloudClient) DeleteUrlMap(name string) error {
+	delete(f.urlMaps, name)
+	return nil
+}
+
+func (f *fakeCloudClient) ListUrlMaps() ([]*compute.UrlMap, error) {
+	urlMaps := make([]*compute.UrlMap, 0, len(f.urlMaps))
+	for _, um := range f.urlMaps {
+		urlMaps = append(urlMaps, um)
+	}
+	return urlMaps, nil
+}
+
+func (f *fakeCloudClient) GetTargetHttpProxy(name string) (*compute.TargetHttpProxy, error) {
+	if tp, ok := f.targetProxies[name]; ok {
+		return tp, nil
+	}
+	return nil, fmt.Errorf("target http proxy %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateTargetHttpProxy(urlMap *compute.UrlMap, name string) (*compute.TargetHttpProxy, error) {
+	tp := &compute.TargetHttpProxy{Name: name, UrlMap: urlMap.SelfLink, SelfLink: name}
+	f.targetProxies[name] = tp
+	return tp, nil
+}
+
+func (f *fakeCloudClient) DeleteTargetHttpProxy(name string) error {
+	delete(f.targetProxies, name)
+	return nil
+}
+
+func (f *fakeCloudClient) SetUrlMapForTargetHttpProxy(proxy *compute.TargetHttpProxy, urlMap *compute.UrlMap) error {
+	proxy.UrlMap = urlMap.SelfLink
+	return nil
+}
+
+// GlobalAddresses
+
+func (f *fakeCloudClient) GetGlobalAddress(name string) (*compute.Address, error) {
+	if addr, ok := f.globalAddresses[name]; ok {
+		return addr, nil
+	}
+	return nil, fmt.Errorf("global address %v not found", name)
+}
+
+func (f *fakeCloudClient) ReserveGlobalAddress(addr *compute.Address) error {
+	if _, ok := f.globalAddresses[addr.Name]; ok {
+		return fmt.Errorf("global address %v already reserved", addr.Name)
+	}
+	addr.SelfLink = addr.Name
+	if addr.Address == "" {
+		addr.Address = fmt.Sprintf("1.2.3.%d", len(f.globalAddresses))
+	}
+	f.globalAddresses[addr.Name] = addr
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteGlobalAddress(name string) error {
+	delete(f.globalAddresses, name)
+	return nil
+}
+
+func (f *fakeCloudClient) CreateGlobalForwardingRuleHTTPS(proxy *compute.TargetHttpsProxy, name string, portRange string) (*compute.ForwardingRule, error) {
+	fw := &compute.ForwardingRule{Name: name, Target: proxy.SelfLink, PortRange: portRange, SelfLink: name}
+	f.forwardingRules[name] = fw
+	return fw, nil
+}
+
+// TargetHttpsProxies
+
+func (f *fakeCloudClient) GetTargetHttpsProxy(name string) (*compute.TargetHttpsProxy, error) {
+	if tp, ok := f.targetHTTPSProxies[name]; ok {
+		return tp, nil
+	}
+	return nil, fmt.Errorf("target https proxy %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateTargetHttpsProxy(urlMap *compute.UrlMap, cert *compute.SslCertificate, name string) (*compute.TargetHttpsProxy, error) {
+	tp := &compute.TargetHttpsProxy{Name: name, UrlMap: urlMap.SelfLink, SslCertificates: []string{cert.SelfLink}, SelfLink: name}
+	f.targetHTTPSProxies[name] = tp
+	return tp, nil
+}
+
+func (f *fakeCloudClient) DeleteTargetHttpsProxy(name string) error {
+	delete(f.targetHTTPSProxies, name)
+	return nil
+}
+
+func (f *fakeCloudClient) SetUrlMapForTargetHttpsProxy(proxy *compute.TargetHttpsProxy, urlMap *compute.UrlMap) error {
+	proxy.UrlMap = urlMap.SelfLink
+	return nil
+}
+
+func (f *fakeCloudClient) SetSslCertificateForTargetHttpsProxy(proxy *compute.TargetHttpsProxy, cert *compute.SslCertificate) error {
+	proxy.SslCertificates = []string{cert.SelfLink}
+	return nil
+}
+
+// SslCertificates
+
+func (f *fakeCloudClient) GetSslCertificate(name string) (*compute.SslCertificate, error) {
+	if cert, ok := f.sslCertificates[name]; ok {
+		return cert, nil
+	}
+	return nil, fmt.Errorf("ssl certificate %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateSslCertificate(cert *compute.SslCertificate) error {
+	cert.SelfLink = cert.Name
+	f.sslCertificates[cert.Name] = cert
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteSslCertificate(name string) error {
+	delete(f.sslCertificates, name)
+	return nil
+}
+
+// Firewalls
+
+func (f *fakeCloudClient) GetFirewall(name string) (*compute.Firewall, error) {
+	if fw, ok := f.firewalls[name]; ok {
+		return fw, nil
+	}
+	return nil, fmt.Errorf("firewall rule %v not found", name)
+}
+
+func (f *fakeCloudClient) CreateFirewall(fw *compute.Firewall) error {
+	fw.SelfLink = fw.Name
+	f.firewalls[fw.Name] = fw
+	return nil
+}
+
+func (f *fakeCloudClient) UpdateFirewall(fw *compute.Firewall) error {
+	f.firewalls[fw.Name] = fw
+	return nil
+}
+
+func (f *fakeCloudClient) DeleteFirewall(name string) error {
+	delete(f.firewalls, name)
+	return nil
+}
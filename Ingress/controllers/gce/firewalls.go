@@ -0,0 +1,106 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/kubernetes/pkg/util/wait"
+
+	"github.com/golang/glog"
+)
+
+// l7SrcRanges are the source IP ranges GCE uses to perform L7 health checks
+// against backends. Every node must allow them through on the NodePort
+// range, or GCE will mark every backend unhealthy.
+var l7SrcRanges = []string{"130.211.0.0/22", "35.191.0.0/16"}
+
+// firewallRulesController reconciles the cluster's single firewall rule,
+// which opens l7SrcRanges to portRange on every node. It's broken out of
+// ClusterManager so it can run its own resync loop independent of backend
+// and loadbalancer syncs, and so it can be driven against a fake Firewalls
+// client in isolation.
+type firewallRulesController struct {
+	cloud     Firewalls
+	namer     *Namer
+	portRange string
+}
+
+// NewFirewallRulesController creates a firewallRulesController keeping a
+// single firewall rule, named by namer, open to l7SrcRanges on portRange
+// (typically the cluster's NodePort range).
+func NewFirewallRulesController(cloud Firewalls, namer *Namer, portRange string) *firewallRulesController {
+	return &firewallRulesController{cloud: cloud, namer: namer, portRange: portRange}
+}
+
+// Run syncs the firewall rule every resync period until stopCh is closed.
+func (fw *firewallRulesController) Run(resync time.Duration, stopCh chan struct{}) {
+	glog.Infof("Starting firewall rules controller")
+	wait.Until(func() {
+		if err := fw.Sync(); err != nil {
+			glog.Errorf("Failed to sync firewall rule: %v", err)
+		}
+	}, resync, stopCh)
+}
+
+// Sync creates the cluster's firewall rule if it doesn't exist, or updates
+// it if its source ranges or allowed ports have drifted. If the rule was
+// adopted by name (--firewall-rule), it's only ever verified, never
+// created or updated: the admin manages it out of band.
+func (fw *firewallRulesController) Sync() error {
+	name := fw.namer.FirewallRuleName()
+	if !fw.namer.ManagesFirewallRule() {
+		if _, err := fw.cloud.GetFirewall(name); err != nil {
+			return fmt.Errorf("could not find user-provided firewall rule %v: %v", name, err)
+		}
+		return nil
+	}
+	rule := &compute.Firewall{
+		Name:         name,
+		SourceRanges: l7SrcRanges,
+		Allowed: []*compute.FirewallAllowed{
+			{IPProtocol: "tcp", Ports: []string{fw.portRange}},
+		},
+	}
+	existing, err := fw.cloud.GetFirewall(name)
+	if err != nil {
+		glog.Infof("Creating firewall rule %v", name)
+		return fw.cloud.CreateFirewall(rule)
+	}
+	if !fw.namer.NameBelongsToCluster(existing.Name) {
+		return fmt.Errorf("firewall rule %v exists but isn't tagged for this cluster, refusing to touch it", name)
+	}
+	if firewallRuleEquals(existing, rule) {
+		return nil
+	}
+	glog.Infof("Updating firewall rule %v", name)
+	rule.SelfLink = existing.SelfLink
+	return fw.cloud.UpdateFirewall(rule)
+}
+
+// firewallRuleEquals returns true if a and b allow the same source ranges
+// and ports.
+func firewallRuleEquals(a, b *compute.Firewall) bool {
+	aRanges, bRanges := append([]string{}, a.SourceRanges...), append([]string{}, b.SourceRanges...)
+	sort.Strings(aRanges)
+	sort.Strings(bRanges)
+	return reflect.DeepEqual(aRanges, bRanges) && reflect.DeepEqual(a.Allowed, b.Allowed)
+}
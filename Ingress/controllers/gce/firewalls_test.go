@@ -0,0 +1,74 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+)
+
+func TestFirewallRulesControllerSyncCreatesRule(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	fw := NewFirewallRulesController(cloud, namer, "30000-32767")
+	if err := fw.Sync(); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	rule, err := cloud.GetFirewall(namer.FirewallRuleName())
+	if err != nil {
+		t.Fatalf("expected a firewall rule to be created, got %v", err)
+	}
+	if rule.Allowed[0].Ports[0] != "30000-32767" {
+		t.Errorf("Ports = %v, want 30000-32767", rule.Allowed[0].Ports)
+	}
+}
+
+func TestFirewallRulesControllerSyncUpdatesDriftedRule(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	fw := NewFirewallRulesController(cloud, namer, "30000-32767")
+	if err := fw.Sync(); err != nil {
+		t.Fatalf("Sync() = %v", err)
+	}
+	fw.portRange = "30000-30010"
+	if err := fw.Sync(); err != nil {
+		t.Fatalf("second Sync() = %v", err)
+	}
+	rule, err := cloud.GetFirewall(namer.FirewallRuleName())
+	if err != nil {
+		t.Fatalf("GetFirewall() = %v", err)
+	}
+	if rule.Allowed[0].Ports[0] != "30000-30010" {
+		t.Errorf("Ports = %v, want 30000-30010 after drift", rule.Allowed[0].Ports)
+	}
+}
+
+func TestFirewallRulesControllerSyncVerifiesAdoptedRule(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1", firewallName: "adopted-rule"}
+	fw := NewFirewallRulesController(cloud, namer, "30000-32767")
+	if err := fw.Sync(); err == nil {
+		t.Fatalf("expected Sync() to fail verifying a firewall rule that doesn't exist yet")
+	}
+	if err := cloud.CreateFirewall(&compute.Firewall{Name: "adopted-rule"}); err != nil {
+		t.Fatalf("CreateFirewall() = %v", err)
+	}
+	if err := fw.Sync(); err != nil {
+		t.Fatalf("expected Sync() to succeed once the adopted rule exists, got %v", err)
+	}
+}
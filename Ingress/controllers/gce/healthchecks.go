@@ -0,0 +1,282 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/intstr"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// Default health check parameters used when a Service has no compatible
+	// readiness probe for us to crib from.
+	defaultHealthCheckInterval = 60
+	defaultHealthyThreshold    = 1
+	defaultUnhealthyThreshold  = 10
+	defaultTimeoutSeconds      = 60
+)
+
+// probeProvider retrieves the readiness probe for a given nodeport, if one
+// exists, so HealthChecks.Add can translate it into a GCE HttpHealthCheck
+// instead of falling back to the global default path.
+type probeProvider interface {
+	GetProbe(port int64) (*api.Probe, error)
+}
+
+// HealthChecks is the single HealthChecker for the cluster: it owns every
+// health check, keyed by NodePort, translating readiness probes into
+// HttpHealthChecks where possible and falling back to a default path
+// otherwise. The default backend's port gets its own default path, since a
+// 404 server rarely answers on the same path as real backends.
+type HealthChecks struct {
+	cloud       SingleHealthCheck
+	defaultPath string
+	namer       *Namer
+	probes      probeProvider
+	// protocols records the protocol ("HTTP" or "HTTPS") each port's health
+	// check was created with, so Delete/GC can tear down the right resource.
+	// Like Backends.knownPorts, this is only populated by Add calls made this
+	// process's lifetime: SingleHealthCheck has no List method, so a port
+	// orphaned by an Ingress/Service deleted across a controller restart is
+	// not reaped by GC the way L7s.GC's ListUrlMaps-backed sweep is.
+	protocols map[int64]string
+
+	defaultBackendPort int64
+	defaultBackendPath string
+}
+
+// NewHealthChecker creates the cluster's single health checker.
+// cloud: the cloud object implementing SingleHealthCheck.
+// defaultHealthCheckPath: is the default path to use for health checks
+//	that don't have a compatible readiness probe to crib from.
+// defaultBackendPort/defaultBackendPath: override defaultHealthCheckPath for
+//	the default 404 backend, which doesn't necessarily serve on the same
+//	path as real backends.
+func NewHealthChecker(cloud SingleHealthCheck, defaultHealthCheckPath string, defaultBackendPort int64, defaultBackendPath string, namer *Namer) HealthChecker {
+	return &HealthChecks{
+		cloud:              cloud,
+		defaultPath:        defaultHealthCheckPath,
+		namer:              namer,
+		protocols:          map[int64]string{},
+		defaultBackendPort: defaultBackendPort,
+		defaultBackendPath: defaultBackendPath,
+	}
+}
+
+// Init wires a probeProvider into the HealthChecks, so subsequent calls to
+// Add can look up a NodePort's readiness probe.
+func (h *HealthChecks) Init(pp probeProvider) error {
+	h.probes = pp
+	return nil
+}
+
+// Add adds a healthcheck for the given ServicePort, deriving its path,
+// timeout, interval and thresholds from the Pods' readiness probe when a
+// compatible one is found, otherwise falling back to the default path. A Service annotated as
+// HTTPS gets an HttpsHealthCheck to match its HTTPS backend.
+func (h *HealthChecks) Add(p ServicePort) error {
+	wantHC := h.defaultHealthCheck(p.Port)
+	if h.probes != nil {
+		probe, err := h.probes.GetProbe(p.Port)
+		if err != nil {
+			glog.Warningf("Could not get probe for port %v, falling back to default health check: %v", p.Port, err)
+		} else if probe != nil {
+			glog.Infof("Found a compatible readiness probe for port %v, deriving health check from it", p.Port)
+			wantHC = h.healthCheckFromProbe(p.Port, probe)
+		}
+	}
+	if oldProtocol, ok := h.protocols[p.Port]; ok && oldProtocol != p.Protocol {
+		glog.Infof("Health check protocol for port %v changed %v -> %v, deleting the old one", p.Port, oldProtocol, p.Protocol)
+		if err := h.deleteProtocol(p.Port, oldProtocol); err != nil {
+			return err
+		}
+	}
+	h.protocols[p.Port] = p.Protocol
+	if p.Protocol == "HTTPS" {
+		if _, err := h.cloud.GetHttpsHealthCheck(wantHC.Name); err == nil {
+			return nil
+		}
+		glog.Infof("Creating https health check %v", wantHC.Name)
+		return h.cloud.CreateHttpsHealthCheck(toHttpsHealthCheck(wantHC))
+	}
+	if _, err := h.Get(p.Port); err == nil {
+		return nil
+	}
+	glog.Infof("Creating health check %v", wantHC.Name)
+	return h.cloud.CreateHttpHealthCheck(wantHC)
+}
+
+// deleteProtocol deletes the HttpHealthCheck or HttpsHealthCheck backing
+// port, whichever protocol names. Split out of Delete so Add can also use it
+// to tear down the stale check left behind when a port's protocol changes.
+func (h *HealthChecks) deleteProtocol(port int64, protocol string) error {
+	name := h.namer.HcName(port)
+	if protocol == "HTTPS" {
+		return h.cloud.DeleteHttpsHealthCheck(name)
+	}
+	return h.cloud.DeleteHttpHealthCheck(name)
+}
+
+// Delete deletes the health check for the given port.
+func (h *HealthChecks) Delete(port int64) error {
+	protocol := h.protocols[port]
+	glog.Infof("Deleting health check %v", h.namer.HcName(port))
+	delete(h.protocols, port)
+	return h.deleteProtocol(port, protocol)
+}
+
+// Get returns the health check for the given port.
+func (h *HealthChecks) Get(port int64) (*compute.HttpHealthCheck, error) {
+	return h.cloud.GetHttpHealthCheck(h.namer.HcName(port))
+}
+
+// SelfLink returns the SelfLink of the health check currently backing port,
+// whichever protocol it is.
+func (h *HealthChecks) SelfLink(port int64) (string, error) {
+	name := h.namer.HcName(port)
+	if h.protocols[port] == "HTTPS" {
+		hc, err := h.cloud.GetHttpsHealthCheck(name)
+		if err != nil {
+			return "", err
+		}
+		return hc.SelfLink, nil
+	}
+	hc, err := h.cloud.GetHttpHealthCheck(name)
+	if err != nil {
+		return "", err
+	}
+	return hc.SelfLink, nil
+}
+
+// Sync ensures every port in the given list has a health check, calling Add
+// with HTTP for any port this HealthChecks hasn't yet seen an Add for.
+func (h *HealthChecks) Sync(ports []int64) error {
+	for _, port := range ports {
+		if _, ok := h.protocols[port]; ok {
+			continue
+		}
+		if err := h.Add(ServicePort{Port: port, Protocol: "HTTP"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GC deletes every health check not in the given list of ports, so Ingress
+// deletion can't leave dangling health checks behind. This only sees ports
+// h.protocols has observed this process's lifetime (see its doc comment) —
+// unlike L7s.GC, it is not restart-safe.
+func (h *HealthChecks) GC(ports []int64) error {
+	knownPorts := map[int64]bool{}
+	for _, port := range ports {
+		knownPorts[port] = true
+	}
+	for port := range h.protocols {
+		if !knownPorts[port] {
+			if err := h.Delete(port); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defaultHealthCheck returns the default health check for port: the
+// cluster-wide default path, or defaultBackendPath if port is the default
+// backend's NodePort.
+func (h *HealthChecks) defaultHealthCheck(port int64) *compute.HttpHealthCheck {
+	path := h.defaultPath
+	if port == h.defaultBackendPort && h.defaultBackendPath != "" {
+		path = h.defaultBackendPath
+	}
+	if path == "" {
+		path = "/"
+	}
+	return &compute.HttpHealthCheck{
+		Name:               h.namer.HcName(port),
+		Port:               port,
+		RequestPath:        path,
+		Host:               "",
+		Description:        "Default kubernetes L7 Loadbalancing health check.",
+		CheckIntervalSec:   defaultHealthCheckInterval,
+		TimeoutSec:         defaultTimeoutSeconds,
+		HealthyThreshold:   defaultHealthyThreshold,
+		UnhealthyThreshold: defaultUnhealthyThreshold,
+	}
+}
+
+// healthCheckFromProbe translates a Pod readiness probe into an
+// HttpHealthCheck for port. Only HTTP GET probes are translatable; anything
+// else (HTTPS, custom headers, exec probes) gets the default health check.
+func (h *HealthChecks) healthCheckFromProbe(port int64, probe *api.Probe) *compute.HttpHealthCheck {
+	httpGet := probe.Handler.HTTPGet
+	if httpGet == nil || httpGet.Scheme == api.URISchemeHTTPS || len(httpGet.HTTPHeaders) != 0 {
+		// GCE HttpHealthChecks don't support custom headers or HTTPS, fall
+		// back to the default.
+		return h.defaultHealthCheck(port)
+	}
+	hc := h.defaultHealthCheck(port)
+	if httpGet.Path != "" {
+		hc.RequestPath = httpGet.Path
+	}
+	if probe.TimeoutSeconds > 0 {
+		hc.TimeoutSec = int64(probe.TimeoutSeconds)
+	}
+	if probe.PeriodSeconds > 0 {
+		hc.CheckIntervalSec = int64(probe.PeriodSeconds)
+	}
+	if probe.SuccessThreshold > 0 {
+		hc.HealthyThreshold = int64(probe.SuccessThreshold)
+	}
+	if probe.FailureThreshold > 0 {
+		hc.UnhealthyThreshold = int64(probe.FailureThreshold)
+	}
+	return hc
+}
+
+// toHttpsHealthCheck copies the fields of an HttpHealthCheck into the
+// equivalent HttpsHealthCheck, since GCE models them as distinct resource
+// types with an otherwise identical schema.
+func toHttpsHealthCheck(hc *compute.HttpHealthCheck) *compute.HttpsHealthCheck {
+	return &compute.HttpsHealthCheck{
+		Name:               hc.Name,
+		Port:               hc.Port,
+		RequestPath:        hc.RequestPath,
+		Host:               hc.Host,
+		Description:        hc.Description,
+		CheckIntervalSec:   hc.CheckIntervalSec,
+		TimeoutSec:         hc.TimeoutSec,
+		HealthyThreshold:   hc.HealthyThreshold,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+	}
+}
+
+// isPortCompatible returns true if the given container port matches the
+// nodeport's target port, so its probe can be used for the nodeport's
+// health check.
+func isPortCompatible(targetPort intstr.IntOrString, containerPort int32, portName string) bool {
+	switch targetPort.Type {
+	case intstr.Int:
+		return targetPort.IntValue() == int(containerPort)
+	case intstr.String:
+		return targetPort.StrVal == portName
+	}
+	return false
+}
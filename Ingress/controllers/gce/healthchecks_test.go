@@ -0,0 +1,102 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+func newTestHealthChecker() (*fakeCloudClient, HealthChecker) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	return cloud, NewHealthChecker(cloud, "/", 0, "/", namer)
+}
+
+func TestHealthCheckerAddDefaultsToHTTP(t *testing.T) {
+	cloud, hc := newTestHealthChecker()
+	if err := hc.Add(ServicePort{Port: 80, Protocol: "HTTP"}); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	if _, err := cloud.GetHttpHealthCheck((&Namer{uid: "uid1"}).HcName(80)); err != nil {
+		t.Errorf("expected an HttpHealthCheck for port 80, got %v", err)
+	}
+}
+
+func TestHealthCheckerAddHTTPSCreatesHTTPSCheck(t *testing.T) {
+	cloud, hc := newTestHealthChecker()
+	if err := hc.Add(ServicePort{Port: 443, Protocol: "HTTPS"}); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	if _, err := cloud.GetHttpsHealthCheck((&Namer{uid: "uid1"}).HcName(443)); err != nil {
+		t.Errorf("expected an HttpsHealthCheck for port 443, got %v", err)
+	}
+}
+
+func TestHealthCheckerAddDeletesStaleCheckOnProtocolSwitch(t *testing.T) {
+	cloud, hc := newTestHealthChecker()
+	name := (&Namer{uid: "uid1"}).HcName(80)
+	if err := hc.Add(ServicePort{Port: 80, Protocol: "HTTP"}); err != nil {
+		t.Fatalf("Add(HTTP) = %v", err)
+	}
+	if err := hc.Add(ServicePort{Port: 80, Protocol: "HTTPS"}); err != nil {
+		t.Fatalf("Add(HTTPS) = %v", err)
+	}
+	if _, err := cloud.GetHttpHealthCheck(name); err == nil {
+		t.Errorf("expected the stale HttpHealthCheck to be deleted once the port switched to HTTPS")
+	}
+	if _, err := cloud.GetHttpsHealthCheck(name); err != nil {
+		t.Errorf("expected an HttpsHealthCheck for port 80, got %v", err)
+	}
+}
+
+func TestHealthCheckerGCDeletesUnknownPorts(t *testing.T) {
+	cloud, hc := newTestHealthChecker()
+	if err := hc.Add(ServicePort{Port: 80, Protocol: "HTTP"}); err != nil {
+		t.Fatalf("Add(80) = %v", err)
+	}
+	if err := hc.Add(ServicePort{Port: 443, Protocol: "HTTPS"}); err != nil {
+		t.Fatalf("Add(443) = %v", err)
+	}
+	if err := hc.GC([]int64{80}); err != nil {
+		t.Fatalf("GC() = %v", err)
+	}
+	if _, err := hc.Get(80); err != nil {
+		t.Errorf("expected port 80's health check to survive GC, got %v", err)
+	}
+	if _, err := cloud.GetHttpsHealthCheck((&Namer{uid: "uid1"}).HcName(443)); err == nil {
+		t.Errorf("expected port 443's health check to be GC'd")
+	}
+}
+
+func TestIsPortCompatible(t *testing.T) {
+	named := intstr.FromString("http")
+	numbered := intstr.FromInt(8080)
+	if !isPortCompatible(numbered, 8080, "") {
+		t.Errorf("expected numbered target port to match equal container port")
+	}
+	if isPortCompatible(numbered, 9090, "") {
+		t.Errorf("expected numbered target port not to match differing container port")
+	}
+	if !isPortCompatible(named, 0, "http") {
+		t.Errorf("expected named target port to match equal port name")
+	}
+	if isPortCompatible(named, 0, "https") {
+		t.Errorf("expected named target port not to match differing port name")
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	compute "google.golang.org/api/compute/v1"
+
+	"github.com/golang/glog"
+)
+
+// Instances implements NodePool, backed by a single GCE Instance Group
+// shared across all backends.
+type Instances struct {
+	cloud InstanceGroups
+	namer *Namer
+}
+
+// NewNodePool creates a new node pool.
+func NewNodePool(cloud InstanceGroups, namer *Namer) NodePool {
+	return &Instances{cloud: cloud, namer: namer}
+}
+
+// Add adds the given nodes to the Instance Group, creating it if necessary.
+func (i *Instances) Add(nodeNames []string) error {
+	name := i.namer.IGName()
+	if _, err := i.cloud.GetInstanceGroup(name); err != nil {
+		glog.Infof("Creating instance group %v", name)
+		if _, err := i.cloud.CreateInstanceGroup(name); err != nil {
+			return err
+		}
+	}
+	return i.cloud.AddInstancesToInstanceGroup(name, nodeNames)
+}
+
+// Remove removes the given nodes from the Instance Group.
+func (i *Instances) Remove(nodeNames []string) error {
+	return i.cloud.RemoveInstancesFromInstanceGroup(i.namer.IGName(), nodeNames)
+}
+
+// Sync syncs the Instance Group membership with the given list of nodes.
+func (i *Instances) Sync(nodeNames []string) error {
+	glog.Infof("Syncing nodes %v", nodeNames)
+	return i.Add(nodeNames)
+}
+
+// Get returns the instance group.
+func (i *Instances) Get(name string) (*compute.InstanceGroup, error) {
+	return i.cloud.GetInstanceGroup(name)
+}
+
+// Shutdown deletes the Instance Group.
+func (i *Instances) Shutdown() error {
+	return i.cloud.DeleteInstanceGroup(i.namer.IGName())
+}
@@ -18,6 +18,7 @@ package main
 
 import (
 	compute "google.golang.org/api/compute/v1"
+	"k8s.io/kubernetes/pkg/apis/extensions"
 )
 
 // This is the structure of the gce l7 controller:
@@ -65,6 +66,14 @@ type NodePool interface {
 	Shutdown() error
 }
 
+// Firewalls is an interface for managing gce firewall rules.
+type Firewalls interface {
+	GetFirewall(name string) (*compute.Firewall, error)
+	CreateFirewall(f *compute.Firewall) error
+	UpdateFirewall(f *compute.Firewall) error
+	DeleteFirewall(name string) error
+}
+
 // InstanceGroups is an interface for managing gce instances groups, and the instances therein.
 type InstanceGroups interface {
 	GetInstanceGroup(name string) (*compute.InstanceGroup, error)
@@ -78,14 +87,23 @@ type InstanceGroups interface {
 	AddPortToInstanceGroup(ig *compute.InstanceGroup, port int64) (*compute.NamedPort, error)
 }
 
+// ServicePort is the tuple identifying a single kubernetes nodePort service:
+// its NodePort, and the Protocol ("HTTP" or "HTTPS") that backend should be
+// addressed with, as derived from the serviceAppProtocolsKey
+// annotation.
+type ServicePort struct {
+	Port     int64
+	Protocol string
+}
+
 // BackendPool is an interface to manage a pool of kubernetes nodePort services
 // as gce backendServices, and sync them through the BackendServices interface.
 type BackendPool interface {
-	Add(port int64) error
+	Add(p ServicePort) error
 	Get(port int64) (*compute.BackendService, error)
 	Delete(port int64) error
-	Sync(ports []int64) error
-	GC(ports []int64) error
+	Sync(svcPorts []ServicePort) error
+	GC(svcPorts []ServicePort) error
 	Shutdown() error
 }
 
@@ -106,6 +124,7 @@ type LoadBalancers interface {
 	// Forwarding Rules
 	GetGlobalForwardingRule(name string) (*compute.ForwardingRule, error)
 	CreateGlobalForwardingRule(proxy *compute.TargetHttpProxy, name string, portRange string) (*compute.ForwardingRule, error)
+	CreateGlobalForwardingRuleHTTPS(proxy *compute.TargetHttpsProxy, name string, portRange string) (*compute.ForwardingRule, error)
 	DeleteGlobalForwardingRule(name string) error
 	SetProxyForGlobalForwardingRule(fw *compute.ForwardingRule, proxy *compute.TargetHttpProxy) error
 
@@ -114,36 +133,76 @@ type LoadBalancers interface {
 	CreateUrlMap(backend *compute.BackendService, name string) (*compute.UrlMap, error)
 	UpdateUrlMap(urlMap *compute.UrlMap) (*compute.UrlMap, error)
 	DeleteUrlMap(name string) error
+	// ListUrlMaps lists every url map in the project, used by L7s.GC to
+	// discover loadbalancers orphaned by an Ingress deleted while the
+	// controller wasn't running to see it (and so never added it to l.pool).
+	ListUrlMaps() ([]*compute.UrlMap, error)
 
 	// TargetProxies
 	GetTargetHttpProxy(name string) (*compute.TargetHttpProxy, error)
 	CreateTargetHttpProxy(urlMap *compute.UrlMap, name string) (*compute.TargetHttpProxy, error)
 	DeleteTargetHttpProxy(name string) error
 	SetUrlMapForTargetHttpProxy(proxy *compute.TargetHttpProxy, urlMap *compute.UrlMap) error
+
+	// GlobalAddresses, used to reserve a static IP per L7. The forwarding
+	// rule create calls take no address, so the reserved IP is not attached
+	// to the forwarding rule.
+	GetGlobalAddress(name string) (*compute.Address, error)
+	ReserveGlobalAddress(addr *compute.Address) error
+	DeleteGlobalAddress(name string) error
+
+	// TargetHttpsProxies, used to terminate TLS at the frontend when an
+	// Ingress has a spec.tls section.
+	GetTargetHttpsProxy(name string) (*compute.TargetHttpsProxy, error)
+	CreateTargetHttpsProxy(urlMap *compute.UrlMap, cert *compute.SslCertificate, name string) (*compute.TargetHttpsProxy, error)
+	DeleteTargetHttpsProxy(name string) error
+	SetUrlMapForTargetHttpsProxy(proxy *compute.TargetHttpsProxy, urlMap *compute.UrlMap) error
+	SetSslCertificateForTargetHttpsProxy(proxy *compute.TargetHttpsProxy, cert *compute.SslCertificate) error
+
+	// SslCertificates
+	GetSslCertificate(name string) (*compute.SslCertificate, error)
+	CreateSslCertificate(cert *compute.SslCertificate) error
+	DeleteSslCertificate(name string) error
 }
 
 // LoadBalancerPool is an interface to manage the cloud resources associated
 // with a gce loadbalancer.
 type LoadBalancerPool interface {
+	Init(tls tlsLoader)
 	Get(name string) (*L7, error)
-	Add(name string) error
+	Add(ing *extensions.Ingress, defaultBackend *compute.BackendService) error
 	Delete(name string) error
-	Sync(names []string) error
+	Sync(ings []*extensions.Ingress, defaultBackend *compute.BackendService) error
 	GC(names []string) error
 	Shutdown() error
 }
 
-// SingleHealthCheck is an interface to manage a single GCE health check.
+// SingleHealthCheck is an interface to manage a single GCE health check. It
+// covers both the HTTP and HTTPS flavors, the latter used to health check
+// backends that terminate TLS themselves (see ServicePort.Protocol).
 type SingleHealthCheck interface {
 	CreateHttpHealthCheck(hc *compute.HttpHealthCheck) error
 	DeleteHttpHealthCheck(name string) error
 	GetHttpHealthCheck(name string) (*compute.HttpHealthCheck, error)
+
+	CreateHttpsHealthCheck(hc *compute.HttpsHealthCheck) error
+	DeleteHttpsHealthCheck(name string) error
+	GetHttpsHealthCheck(name string) (*compute.HttpsHealthCheck, error)
 }
 
 // HealthChecker is an interface to manage the cloud resources associated with
-// health checking. Currently it's just a think wrapper around HealthCheck.
+// health checking. A single HealthChecker instance owns every health check
+// in the cluster, keyed by NodePort, so BackendPool never has to wrap or
+// duplicate one per backend (including the default backend).
 type HealthChecker interface {
-	Add(name string) error
-	Delete(name string) error
-	Get(name string) (*compute.HttpHealthCheck, error)
+	Init(pp probeProvider) error
+	Add(p ServicePort) error
+	Delete(port int64) error
+	Get(port int64) (*compute.HttpHealthCheck, error)
+	// SelfLink returns the SelfLink of the health check backing port,
+	// whichever of HttpHealthCheck/HttpsHealthCheck it currently is, for
+	// BackendPool to reference from a BackendService.HealthChecks entry.
+	SelfLink(port int64) (string, error)
+	Sync(ports []int64) error
+	GC(ports []int64) error
 }
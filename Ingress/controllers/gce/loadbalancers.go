@@ -0,0 +1,435 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+
+	"github.com/golang/glog"
+)
+
+// L7 represents a single GCE L7 loadbalancer, identified by a unique name
+// (usually the Ingress' namespace/name). The url map always points at HTTP
+// backends; an HTTPS ServicePort (see annotations.go) only changes how the
+// backend itself is addressed, not the proxy GCE uses to reach it. TLS
+// termination at the frontend adds a TargetHttpsProxy over the same url map
+// (see edgeHopHTTPS).
+type L7 struct {
+	Name  string
+	cloud LoadBalancers
+	namer *Namer
+	// ipName is the name of the GlobalAddress fronting this L7. It's either
+	// user-provided (staticIPNameKey) or managed by managesIP below.
+	ipName    string
+	managesIP bool
+	um        *compute.UrlMap
+	tp        *compute.TargetHttpProxy
+	fw        *compute.ForwardingRule
+	ip        *compute.Address
+
+	// cert, tps and fws are only populated when the Ingress has a spec.tls
+	// section (or --tls-pre-shared-cert is set), fronting the same um with
+	// a second, HTTPS forwarding rule on port 443. tls is only populated from
+	// spec.tls when no pre-shared cert is set.
+	tls           *TLSCerts
+	preSharedCert string
+	cert          *compute.SslCertificate
+	tps           *compute.TargetHttpsProxy
+	fws           *compute.ForwardingRule
+}
+
+// L7s implements LoadBalancerPool, managing the lifecycle of every L7
+// loadbalancer backed by the cluster's Ingresses.
+type L7s struct {
+	cloud LoadBalancers
+	namer *Namer
+	pool  map[string]*L7
+	tls   tlsLoader
+	// preSharedCert, when set (via --tls-pre-shared-cert), names an
+	// SslCertificate the admin created out of band; the controller neither
+	// creates nor deletes it, just points target proxies at it.
+	preSharedCert string
+}
+
+// NewLoadBalancerPool creates a new loadbalancer pool. preSharedCert, if
+// non-empty, names an SslCertificate created out of band by the admin; it
+// takes precedence over any Secret referenced by an Ingress' spec.tls.
+func NewLoadBalancerPool(cloud LoadBalancers, namer *Namer, preSharedCert string) LoadBalancerPool {
+	return &L7s{cloud: cloud, namer: namer, pool: map[string]*L7{}, preSharedCert: preSharedCert}
+}
+
+// Init wires a tlsLoader into the pool, used to resolve the kubernetes.io/tls
+// Secret referenced by an Ingress' spec.tls into cert/key bytes.
+func (l *L7s) Init(tls tlsLoader) {
+	l.tls = tls
+}
+
+// Get returns the L7 for the given name.
+func (l *L7s) Get(name string) (*L7, error) {
+	lb, ok := l.pool[name]
+	if !ok {
+		return nil, fmt.Errorf("loadbalancer %v not found", name)
+	}
+	return lb, nil
+}
+
+// Add creates the GCE resources (static IP, url map, target proxy,
+// forwarding rule, and if requested an HTTPS counterpart) needed to front
+// defaultBackend for ing, if they don't already exist.
+func (l *L7s) Add(ing *extensions.Ingress, defaultBackend *compute.BackendService) error {
+	name := ingressKey(ing)
+	lb, ok := l.pool[name]
+	if !ok {
+		lb = &L7{Name: name, cloud: l.cloud, namer: l.namer}
+		l.pool[name] = lb
+	}
+	lb.ipName = staticIPName(ing)
+	lb.managesIP = lb.ipName == ""
+	if lb.managesIP {
+		lb.ipName = l.namer.IPName(name)
+	}
+	lb.preSharedCert = l.preSharedCert
+	lb.tls = nil
+	if lb.preSharedCert == "" && len(ing.Spec.TLS) != 0 && l.tls != nil {
+		tls, err := l.tls.GetTLSCerts(ing)
+		if err != nil {
+			return err
+		}
+		lb.tls = tls
+	}
+	return lb.edgeHop(defaultBackend)
+}
+
+// Delete deletes the GCE resources associated with the named loadbalancer.
+// lb need not be in the pool (eg after a restart, before its Ingress was
+// re-Added): Cleanup resolves every resource by its Namer-derived name
+// rather than relying on lb's in-memory state.
+func (l *L7s) Delete(name string) error {
+	lb, ok := l.pool[name]
+	if !ok {
+		lb = &L7{Name: name, cloud: l.cloud, namer: l.namer, preSharedCert: l.preSharedCert}
+	}
+	defer delete(l.pool, name)
+	return lb.Cleanup()
+}
+
+// Sync syncs the pool with the given list of Ingresses, creating
+// loadbalancers that don't yet exist.
+func (l *L7s) Sync(ings []*extensions.Ingress, defaultBackend *compute.BackendService) error {
+	glog.Infof("Syncing %v loadbalancers", len(ings))
+	for _, ing := range ings {
+		if err := l.Add(ing, defaultBackend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GC garbage collects loadbalancers not in the given list of names. It
+// sweeps both the in-memory pool and, by listing every url map in the
+// project and decoding its name, any loadbalancer this process never
+// Added — eg one whose Ingress was deleted after a controller restart, and
+// so was never re-populated into l.pool.
+func (l *L7s) GC(names []string) error {
+	knownLoadBalancers := map[string]bool{}
+	for _, n := range names {
+		knownLoadBalancers[n] = true
+	}
+	toDelete := map[string]bool{}
+	for name := range l.pool {
+		if !knownLoadBalancers[name] {
+			toDelete[name] = true
+		}
+	}
+	urlMaps, err := l.cloud.ListUrlMaps()
+	if err != nil {
+		return err
+	}
+	for _, um := range urlMaps {
+		name, ok := l.namer.ParseName(um.Name)
+		if !ok || knownLoadBalancers[name] {
+			continue
+		}
+		toDelete[name] = true
+	}
+	for name := range toDelete {
+		if err := l.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown deletes every loadbalancer known to the pool.
+func (l *L7s) Shutdown() error {
+	for name := range l.pool {
+		if err := l.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// edgeHop creates or updates the chain of GCE resources (url map -> target
+// proxy -> forwarding rule, plus a static ip) that front this L7's backend, plus
+// a second, HTTPS chain on port 443 when the Ingress has a spec.tls section
+// or a pre-shared cert was configured.
+func (l *L7) edgeHop(defaultBackend *compute.BackendService) error {
+	um, err := l.checkUrlMap(defaultBackend)
+	if err != nil {
+		return err
+	}
+	tp, err := l.checkTargetHttpProxy(um)
+	if err != nil {
+		return err
+	}
+	if err := l.checkStaticIP(); err != nil {
+		return err
+	}
+	if err := l.checkForwardingRule(tp); err != nil {
+		return err
+	}
+	if l.preSharedCert == "" && l.tls == nil {
+		// The Ingress has no spec.tls (any more — it may have had one before
+		// this L7's last Add), and no pre-shared cert is configured, so any
+		// HTTPS resources from a previous sync need tearing down.
+		return l.cleanupHTTPS()
+	}
+	return l.edgeHopHTTPS(um)
+}
+
+// edgeHopHTTPS creates or updates the SslCertificate, target https proxy
+// and port 443 forwarding rule needed to terminate TLS at this L7.
+func (l *L7) edgeHopHTTPS(um *compute.UrlMap) error {
+	cert, err := l.checkSslCertificate()
+	if err != nil {
+		return err
+	}
+	tps, err := l.checkTargetHttpsProxy(um, cert)
+	if err != nil {
+		return err
+	}
+	return l.checkForwardingRuleHTTPS(tps)
+}
+
+func (l *L7) checkUrlMap(defaultBackend *compute.BackendService) (*compute.UrlMap, error) {
+	name := l.namer.UMName(l.Name)
+	um, err := l.cloud.GetUrlMap(name)
+	if um == nil || err != nil {
+		glog.Infof("Creating url map %v", name)
+		um, err = l.cloud.CreateUrlMap(defaultBackend, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.um = um
+	return um, nil
+}
+
+func (l *L7) checkTargetHttpProxy(um *compute.UrlMap) (*compute.TargetHttpProxy, error) {
+	name := l.namer.TPName(l.Name)
+	tp, err := l.cloud.GetTargetHttpProxy(name)
+	if tp == nil || err != nil {
+		glog.Infof("Creating target http proxy %v", name)
+		tp, err = l.cloud.CreateTargetHttpProxy(um, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.tp = tp
+	return tp, nil
+}
+
+// checkStaticIP reserves a GlobalStaticIP for this L7, named after it unless
+// the Ingress requested a pre-reserved, user-provided address. A 409/400
+// from the reservation call means the address already exists (either
+// reserved by a previous sync, or adopted), which we treat as success. The
+// address is reserved before, and independently of, the forwarding rule:
+// checkForwardingRule creates the rule without it.
+func (l *L7) checkStaticIP() error {
+	ip, err := l.cloud.GetGlobalAddress(l.ipName)
+	if err == nil {
+		l.ip = ip
+		return nil
+	}
+	if !l.managesIP {
+		return fmt.Errorf("could not find user-provided static IP %v: %v", l.ipName, err)
+	}
+	glog.Infof("Reserving static IP %v", l.ipName)
+	err = l.cloud.ReserveGlobalAddress(&compute.Address{Name: l.ipName})
+	if err != nil && !IsHTTPErrorCode(err, 409) && !IsHTTPErrorCode(err, 400) {
+		return err
+	}
+	ip, err = l.cloud.GetGlobalAddress(l.ipName)
+	if err != nil {
+		return err
+	}
+	l.ip = ip
+	return nil
+}
+
+func (l *L7) checkForwardingRule(tp *compute.TargetHttpProxy) error {
+	name := l.namer.FrName(l.Name)
+	fw, err := l.cloud.GetGlobalForwardingRule(name)
+	if fw == nil || err != nil {
+		glog.Infof("Creating forwarding rule %v", name)
+		fw, err = l.cloud.CreateGlobalForwardingRule(tp, name, "80-80")
+		if err != nil {
+			return err
+		}
+	}
+	l.fw = fw
+	return nil
+}
+
+// checkSslCertificate resolves the cert this L7 should present: the
+// pre-shared cert if one was configured, otherwise a cert synced from the
+// Ingress' spec.tls Secret, creating it if it doesn't already exist.
+func (l *L7) checkSslCertificate() (*compute.SslCertificate, error) {
+	if l.preSharedCert != "" {
+		cert, err := l.cloud.GetSslCertificate(l.preSharedCert)
+		if err != nil {
+			return nil, fmt.Errorf("could not find pre-shared cert %v: %v", l.preSharedCert, err)
+		}
+		l.cert = cert
+		return cert, nil
+	}
+	name := l.namer.CertName(l.Name, l.tls.hash())
+	cert, err := l.cloud.GetSslCertificate(name)
+	if cert == nil || err != nil {
+		glog.Infof("Creating ssl certificate %v", name)
+		cert = &compute.SslCertificate{Name: name, Certificate: l.tls.Cert, PrivateKey: l.tls.Key}
+		if err := l.cloud.CreateSslCertificate(cert); err != nil {
+			return nil, err
+		}
+	}
+	l.cert = cert
+	return cert, nil
+}
+
+// checkTargetHttpsProxy creates the target https proxy if it doesn't exist,
+// or swaps in a rotated cert otherwise.
+func (l *L7) checkTargetHttpsProxy(um *compute.UrlMap, cert *compute.SslCertificate) (*compute.TargetHttpsProxy, error) {
+	name := l.namer.TPSName(l.Name)
+	tps, err := l.cloud.GetTargetHttpsProxy(name)
+	if tps == nil || err != nil {
+		glog.Infof("Creating target https proxy %v", name)
+		tps, err = l.cloud.CreateTargetHttpsProxy(um, cert, name)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(tps.SslCertificates) == 0 || tps.SslCertificates[0] != cert.SelfLink {
+		glog.Infof("Rotating ssl certificate on target https proxy %v", name)
+		oldCert := ""
+		if len(tps.SslCertificates) != 0 {
+			oldCert = tps.SslCertificates[0]
+		}
+		if err := l.cloud.SetSslCertificateForTargetHttpsProxy(tps, cert); err != nil {
+			return nil, err
+		}
+		if l.preSharedCert == "" && oldCert != "" && oldCert != cert.SelfLink {
+			if err := l.cloud.DeleteSslCertificate(oldCert); err != nil {
+				glog.Warningf("Failed to GC stale ssl certificate %v: %v", oldCert, err)
+			}
+		}
+	}
+	l.tps = tps
+	return tps, nil
+}
+
+func (l *L7) checkForwardingRuleHTTPS(tps *compute.TargetHttpsProxy) error {
+	name := l.namer.FrsName(l.Name)
+	fws, err := l.cloud.GetGlobalForwardingRule(name)
+	if fws == nil || err != nil {
+		glog.Infof("Creating https forwarding rule %v", name)
+		fws, err = l.cloud.CreateGlobalForwardingRuleHTTPS(tps, name, "443-443")
+		if err != nil {
+			return err
+		}
+	}
+	l.fws = fws
+	return nil
+}
+
+// cleanupHTTPS deletes the port 443 forwarding rule, target https proxy and
+// (unless pre-shared) ssl certificate fronting this L7, if they exist. A
+// no-op if they don't, so it's safe to call both when tearing down the whole
+// L7 (Cleanup) and when an Ingress keeps its HTTP resources but drops
+// spec.tls (edgeHop). Resources are resolved by their Namer-derived name
+// rather than l's in-memory tps/fws/cert fields, for the same restart-safety
+// reasons as Cleanup.
+func (l *L7) cleanupHTTPS() error {
+	tpsName := l.namer.TPSName(l.Name)
+	tps, _ := l.cloud.GetTargetHttpsProxy(tpsName)
+	if tps == nil {
+		return nil
+	}
+	if err := l.cloud.DeleteGlobalForwardingRule(l.namer.FrsName(l.Name)); err != nil && !isNotFoundErr(err) {
+		return err
+	}
+	if err := l.cloud.DeleteTargetHttpsProxy(tpsName); err != nil && !isNotFoundErr(err) {
+		return err
+	}
+	// A pre-shared cert's live state can't be told apart from a
+	// controller-created one by name alone (the certHash component of
+	// CertName isn't recoverable without the live Secret), so fall back
+	// to the one thing that is recoverable: whether this L7 was
+	// configured with a pre-shared cert at all.
+	if l.preSharedCert == "" && len(tps.SslCertificates) != 0 {
+		if err := l.cloud.DeleteSslCertificate(tps.SslCertificates[0]); err != nil && !isNotFoundErr(err) {
+			return err
+		}
+	}
+	l.tps, l.fws, l.cert = nil, nil, nil
+	return nil
+}
+
+// Cleanup deletes the forwarding rule(s), target proxies, url map and (if
+// controller-managed) static IP and ssl certificate for this L7, in
+// dependency order. Every resource is resolved by its Namer-derived name
+// rather than l's in-memory um/tp/tps/cert/ipName/managesIP fields, which
+// are empty on an L7 reconstructed as a stub after a controller restart
+// (see L7s.Delete) — a resource's name is fully determined by (namer.uid,
+// l.Name), so there's nothing to recover from memory that a Get can't
+// answer just as well.
+func (l *L7) Cleanup() error {
+	if err := l.cloud.DeleteGlobalForwardingRule(l.namer.FrName(l.Name)); err != nil && !isNotFoundErr(err) {
+		return err
+	}
+	if err := l.cloud.DeleteTargetHttpProxy(l.namer.TPName(l.Name)); err != nil && !isNotFoundErr(err) {
+		return err
+	}
+	if err := l.cleanupHTTPS(); err != nil {
+		return err
+	}
+	if err := l.cloud.DeleteUrlMap(l.namer.UMName(l.Name)); err != nil && !isNotFoundErr(err) {
+		return err
+	}
+	// A user-provided static IP (the global-static-ip-name annotation) never
+	// carries this name, so this Get simply won't find it and it's left
+	// alone, same as if managesIP had been recovered and found false.
+	ipName := l.namer.IPName(l.Name)
+	if _, err := l.cloud.GetGlobalAddress(ipName); err == nil {
+		if err := l.cloud.DeleteGlobalAddress(ipName); err != nil && !isNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
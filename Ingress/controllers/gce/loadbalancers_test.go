@@ -0,0 +1,192 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	compute "google.golang.org/api/compute/v1"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+// fakeTLSLoader returns the same TLSCerts for every Ingress, regardless of
+// its spec.tls Secret reference.
+type fakeTLSLoader struct {
+	certs *TLSCerts
+}
+
+func (f *fakeTLSLoader) GetTLSCerts(ing *extensions.Ingress) (*TLSCerts, error) {
+	return f.certs, nil
+}
+
+func newTestIngress(name string, tls bool) *extensions.Ingress {
+	ing := &extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{Namespace: "ns1", Name: name},
+	}
+	if tls {
+		ing.Spec.TLS = []extensions.IngressTLS{{SecretName: "ing-secret"}}
+	}
+	return ing
+}
+
+func TestL7sAddCreatesHTTPResources(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	pool := NewLoadBalancerPool(cloud, namer, "")
+	pool.Init(&fakeTLSLoader{})
+
+	ing := newTestIngress("ing1", false)
+	defaultBackend := &compute.BackendService{Name: "default-be", SelfLink: "default-be"}
+	if err := pool.Add(ing, defaultBackend); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+
+	name := ingressKey(ing)
+	if _, err := cloud.GetUrlMap(namer.UMName(name)); err != nil {
+		t.Errorf("expected a url map, got %v", err)
+	}
+	if _, err := cloud.GetTargetHttpProxy(namer.TPName(name)); err != nil {
+		t.Errorf("expected a target http proxy, got %v", err)
+	}
+	if _, err := cloud.GetGlobalForwardingRule(namer.FrName(name)); err != nil {
+		t.Errorf("expected a forwarding rule, got %v", err)
+	}
+	if _, err := cloud.GetGlobalAddress(namer.IPName(name)); err != nil {
+		t.Errorf("expected a managed static IP, got %v", err)
+	}
+	if _, err := cloud.GetTargetHttpsProxy(namer.TPSName(name)); err == nil {
+		t.Errorf("expected no target https proxy for a non-TLS Ingress")
+	}
+}
+
+func TestL7sAddWithTLSCreatesHTTPSResources(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	pool := NewLoadBalancerPool(cloud, namer, "")
+	pool.Init(&fakeTLSLoader{certs: &TLSCerts{Cert: "cert", Key: "key"}})
+
+	ing := newTestIngress("ing1", true)
+	defaultBackend := &compute.BackendService{Name: "default-be", SelfLink: "default-be"}
+	if err := pool.Add(ing, defaultBackend); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+
+	name := ingressKey(ing)
+	if _, err := cloud.GetTargetHttpsProxy(namer.TPSName(name)); err != nil {
+		t.Errorf("expected a target https proxy, got %v", err)
+	}
+	if _, err := cloud.GetGlobalForwardingRule(namer.FrsName(name)); err != nil {
+		t.Errorf("expected a port 443 forwarding rule, got %v", err)
+	}
+}
+
+// TestL7sAddTogglingTLSOffTearsDownHTTPSResources covers an Ingress edited to
+// drop its spec.tls section: the next Add should tear down the now-stale
+// HTTPS resources rather than leaving them behind because lb.tls is sticky.
+func TestL7sAddTogglingTLSOffTearsDownHTTPSResources(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	pool := NewLoadBalancerPool(cloud, namer, "")
+	pool.Init(&fakeTLSLoader{certs: &TLSCerts{Cert: "cert", Key: "key"}})
+
+	ing := newTestIngress("ing1", true)
+	defaultBackend := &compute.BackendService{Name: "default-be", SelfLink: "default-be"}
+	if err := pool.Add(ing, defaultBackend); err != nil {
+		t.Fatalf("Add() with tls = %v", err)
+	}
+	name := ingressKey(ing)
+	if _, err := cloud.GetTargetHttpsProxy(namer.TPSName(name)); err != nil {
+		t.Fatalf("expected a target https proxy before toggling tls off, got %v", err)
+	}
+
+	ing.Spec.TLS = nil
+	if err := pool.Add(ing, defaultBackend); err != nil {
+		t.Fatalf("Add() after dropping tls = %v", err)
+	}
+	if _, err := cloud.GetTargetHttpsProxy(namer.TPSName(name)); err == nil {
+		t.Errorf("expected the target https proxy to be torn down once tls was removed")
+	}
+	if _, err := cloud.GetGlobalForwardingRule(namer.FrsName(name)); err == nil {
+		t.Errorf("expected the port 443 forwarding rule to be torn down once tls was removed")
+	}
+	if _, err := cloud.GetUrlMap(namer.UMName(name)); err != nil {
+		t.Errorf("expected the url map (still fronting HTTP) to survive, got %v", err)
+	}
+}
+
+// TestL7sDeleteCleansUpAfterRestart simulates a controller restart (a fresh
+// L7s sharing the same cloud and namer, with an empty in-memory pool) asked
+// to delete a loadbalancer it never Added itself.
+func TestL7sDeleteCleansUpAfterRestart(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	pool := NewLoadBalancerPool(cloud, namer, "")
+	pool.Init(&fakeTLSLoader{certs: &TLSCerts{Cert: "cert", Key: "key"}})
+
+	ing := newTestIngress("ing1", true)
+	defaultBackend := &compute.BackendService{Name: "default-be", SelfLink: "default-be"}
+	if err := pool.Add(ing, defaultBackend); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	name := ingressKey(ing)
+
+	restarted := NewLoadBalancerPool(cloud, namer, "")
+	if err := restarted.Delete(name); err != nil {
+		t.Fatalf("Delete() on a never-Added pool = %v", err)
+	}
+
+	if _, err := cloud.GetUrlMap(namer.UMName(name)); err == nil {
+		t.Errorf("expected the url map to be cleaned up")
+	}
+	if _, err := cloud.GetGlobalForwardingRule(namer.FrName(name)); err == nil {
+		t.Errorf("expected the forwarding rule to be cleaned up")
+	}
+	if _, err := cloud.GetTargetHttpsProxy(namer.TPSName(name)); err == nil {
+		t.Errorf("expected the target https proxy to be cleaned up")
+	}
+	if _, err := cloud.GetGlobalForwardingRule(namer.FrsName(name)); err == nil {
+		t.Errorf("expected the port 443 forwarding rule to be cleaned up")
+	}
+	if _, err := cloud.GetGlobalAddress(namer.IPName(name)); err == nil {
+		t.Errorf("expected the managed static IP to be cleaned up")
+	}
+}
+
+func TestL7sGCDiscoversOrphanedLoadBalancer(t *testing.T) {
+	cloud := newFakeCloudClient()
+	namer := &Namer{uid: "uid1"}
+	pool := NewLoadBalancerPool(cloud, namer, "")
+	pool.Init(&fakeTLSLoader{})
+
+	ing := newTestIngress("orphan", false)
+	defaultBackend := &compute.BackendService{Name: "default-be", SelfLink: "default-be"}
+	if err := pool.Add(ing, defaultBackend); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	name := ingressKey(ing)
+
+	// A fresh pool, as after a restart, never saw "orphan" get Added; its
+	// only trace is the url map GC discovers via ListUrlMaps.
+	restarted := NewLoadBalancerPool(cloud, namer, "")
+	if err := restarted.GC([]string{}); err != nil {
+		t.Fatalf("GC() = %v", err)
+	}
+	if _, err := cloud.GetUrlMap(namer.UMName(name)); err == nil {
+		t.Errorf("expected GC to discover and delete the orphaned url map")
+	}
+}
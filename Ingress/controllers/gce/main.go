@@ -55,12 +55,19 @@ var (
 		printed to stdout and no changes are made to your cluster. This flag is for
 		testing.`)
 
-	clusterName = flags.String("gce-cluster-name", "foo",
-		`Optional, used to tag cluster wide, shared loadbalancer resources such
-		 as instance groups. Use this flag if you'd like to continue using the
-		 same resources across a pod restart. Note that this does not need to
-		 match the name of you Kubernetes cluster, it's just an arbitrary name
-		 used to tag/lookup cloud resources.`)
+	clusterName = flags.String("gce-cluster-name", "",
+		`Optional. The cluster UID used to tag cluster wide, shared
+		loadbalancer resources is persisted in a kube-system ConfigMap and
+		recovered across restarts automatically; this flag only seeds that
+		ConfigMap the first time it's created, so admins upgrading from a
+		version that used it as the tag directly can carry the old value
+		over instead of orphaning existing resources.`)
+
+	firewallRuleName = flags.String("firewall-rule", "",
+		`Optional, the name of a pre-existing firewall rule, created out of
+		band, allowing GCE's health check ranges into every node's NodePort
+		range. If specified, the controller only verifies it exists instead
+		of syncing it from --node-port-range.`)
 
 	inCluster = flags.Bool("running-in-cluster", true,
 		`Optional, if this controller is running in a kubernetes cluster, use the
@@ -83,6 +90,23 @@ var (
 	healthCheckPath = flags.String("health-check-path", "/",
 		`Path used to health-check a backend service. All Services must serve
 		a 200 page on this path. Currently this is only configurable globally.`)
+
+	defaultSvcHealthCheckPath = flags.String("default-svc-health-check-path", "/healthz",
+		`Path used to health-check the default backend service specified by
+		--default-backend-node-port. Distinct from --health-check-path
+		because the default backend is typically a dedicated 404 server,
+		not expected to serve real backends' health check path.`)
+
+	tlsPreSharedCert = flags.String("tls-pre-shared-cert", "",
+		`Optional, the name of a pre-existing GCE SslCertificate resource
+		created out of band. If specified, the controller points every
+		Ingress' HTTPS target proxy at this cert instead of syncing certs
+		from kubernetes.io/tls Secrets.`)
+
+	nodePortRange = flags.String("node-port-range", "30000-32767",
+		`Node port range used by Kubernetes Services of type NodePort. The
+		firewall rules controller opens this range, cluster wide, to GCE's
+		health check source ranges so backends can be health checked.`)
 )
 
 func registerHandlers(lbc *loadBalancerController) {
@@ -147,32 +171,50 @@ func main() {
 			kubeClient, err = client.New(config)
 		}
 	}
+	// The same cloud client (real or fake) is shared by every sub-controller
+	// through the ControllerContext built below, so there's a single place
+	// --proxy/--running-in-cluster dry-run selection happens.
+	var cloud cloudInterface
 	if *proxyUrl == "" && *inCluster {
-		// Create cluster manager
-		clusterManager, err = NewClusterManager(
-			*clusterName, *defaultBackendNodePort, *healthCheckPath)
-		if err != nil {
-			glog.Fatalf("%v", err)
-		}
+		cloud, err = getGCECloud()
 	} else {
-		// Create fake cluster manager
-		fcm, err := newFakeClusterManager(*clusterName)
-		if err != nil {
-			glog.Fatalf("%v", err)
-		}
-		clusterManager = fcm.ClusterManager
+		cloud = newFakeCloudClient()
+	}
+	if err != nil {
+		glog.Fatalf("%v", err)
+	}
+
+	namer, err := NewNamer(kubeClient, *clusterName, *firewallRuleName)
+	if err != nil {
+		glog.Fatalf("%v", err)
+	}
+
+	// queue is created before ctx because ctx's informers need its enqueue
+	// func at construction time, but queue itself can't sync anything until
+	// lbc (which needs ctx) exists; queue.sync is back-patched once lbc is
+	// built, before anything is started.
+	queue := NewTaskQueue(nil)
+	ctx := NewControllerContext(kubeClient, cloud, namer, *resyncPeriod, queue.enqueue)
+	clusterManager, err = NewClusterManager(
+		ctx, *defaultBackendNodePort, *healthCheckPath, *defaultSvcHealthCheckPath, *tlsPreSharedCert, *nodePortRange)
+	if err != nil {
+		glog.Fatalf("%v", err)
 	}
 
 	// Start loadbalancer controller
-	lbc, err := NewLoadBalancerController(kubeClient, clusterManager, *resyncPeriod)
+	lbc, err := NewLoadBalancerController(ctx, clusterManager)
 	if err != nil {
 		glog.Fatalf("%v", err)
 	}
+	queue.sync = lbc.sync
 	glog.Infof("Created lbc %+v", lbc)
 	go registerHandlers(lbc)
 	if *deleteAllOnQuit {
 		go handleSigterm(lbc)
 	}
+	go ctx.Run()
+	queue.run(*resyncPeriod, ctx.StopCh)
+	go clusterManager.firewallController.Run(*resyncPeriod, ctx.StopCh)
 	lbc.Run()
 	for {
 		glog.Infof("Handled quit, awaiting pod deletion.")
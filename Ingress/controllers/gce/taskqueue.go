@@ -0,0 +1,82 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	"k8s.io/kubernetes/pkg/util/wait"
+	"k8s.io/kubernetes/pkg/util/workqueue"
+
+	"github.com/golang/glog"
+)
+
+// syncKey is the single item every informer enqueues: every sync reconciles
+// complete desired state (every Ingress, Service and Node) regardless of
+// which object changed, so there's nothing to gain from keying the queue by
+// object identity, only from debouncing bursts of events into one sync.
+const syncKey = "sync"
+
+// taskQueue debounces repeated enqueues landing before the worker gets to
+// them into a single call of sync, retrying with backoff on error.
+type taskQueue struct {
+	queue workqueue.RateLimitingInterface
+	sync  func(key string) error
+}
+
+// NewTaskQueue creates a taskQueue that calls syncFn on every drained item.
+func NewTaskQueue(syncFn func(key string) error) *taskQueue {
+	return &taskQueue{
+		queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		sync:  syncFn,
+	}
+}
+
+// enqueue schedules a sync. Safe to call from any informer's event handler.
+func (t *taskQueue) enqueue() {
+	t.queue.Add(syncKey)
+}
+
+func (t *taskQueue) worker() {
+	for {
+		key, quit := t.queue.Get()
+		if quit {
+			return
+		}
+		if err := t.sync(key.(string)); err != nil {
+			glog.Errorf("Requeuing sync, err: %v", err)
+			t.queue.AddRateLimited(key)
+		} else {
+			t.queue.Forget(key)
+		}
+		t.queue.Done(key)
+	}
+}
+
+// run starts the worker loop until stopCh is closed.
+func (t *taskQueue) run(period time.Duration, stopCh <-chan struct{}) {
+	go wait.Until(t.worker, period, stopCh)
+	go func() {
+		<-stopCh
+		t.shutdown()
+	}()
+}
+
+// shutdown stops the queue, causing worker to return.
+func (t *taskQueue) shutdown() {
+	t.queue.ShutDown()
+}
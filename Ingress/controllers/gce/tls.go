@@ -0,0 +1,84 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+// TLSCerts holds the PEM encoded certificate and private key backing an
+// SslCertificate, as read out of a kubernetes.io/tls Secret.
+type TLSCerts struct {
+	Cert string
+	Key  string
+}
+
+// tlsLoader resolves the kubernetes.io/tls Secret(s) referenced by an
+// Ingress' spec.tls into PEM cert/key bytes, so L7 can sync them into a GCE
+// SslCertificate. Injected into LoadBalancerPool via Init, mirroring how
+// HealthChecker.Init(probeProvider) wires in Pod probe lookups.
+type tlsLoader interface {
+	GetTLSCerts(ing *extensions.Ingress) (*TLSCerts, error)
+}
+
+// apiServerTLSLoader reads the TLS Secret(s) referenced by spec.tls straight
+// from the apiserver. Only the first spec.tls entry is honored; GCE target
+// proxies in this version of the controller serve a single certificate.
+type apiServerTLSLoader struct {
+	client *client.Client
+}
+
+// newAPIServerTLSLoader returns a tlsLoader backed by kubeClient.
+func newAPIServerTLSLoader(kubeClient *client.Client) tlsLoader {
+	return &apiServerTLSLoader{client: kubeClient}
+}
+
+// GetTLSCerts fetches the Secret named by the first spec.tls entry on ing
+// and returns its tls.crt/tls.key.
+func (t *apiServerTLSLoader) GetTLSCerts(ing *extensions.Ingress) (*TLSCerts, error) {
+	if len(ing.Spec.TLS) == 0 {
+		return nil, nil
+	}
+	secretName := ing.Spec.TLS[0].SecretName
+	secret, err := t.client.Secrets(ing.Namespace).Get(secretName)
+	if err != nil {
+		return nil, fmt.Errorf("could not find Secret %v/%v referenced by Ingress %v/%v: %v", ing.Namespace, secretName, ing.Namespace, ing.Name, err)
+	}
+	cert, ok := secret.Data[api.TLSCertKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret %v/%v has no %v", ing.Namespace, secretName, api.TLSCertKey)
+	}
+	key, ok := secret.Data[api.TLSPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret %v/%v has no %v", ing.Namespace, secretName, api.TLSPrivateKeyKey)
+	}
+	return &TLSCerts{Cert: string(cert), Key: string(key)}, nil
+}
+
+// hash returns a short, stable identifier for the cert's content, used to
+// name the SslCertificate so a rotation (new cert/key) produces a new
+// resource the controller can swap the target proxy to before GC'ing the
+// stale one.
+func (t *TLSCerts) hash() string {
+	sum := sha256.Sum256([]byte(t.Cert + t.Key))
+	return fmt.Sprintf("%x", sum)[:16]
+}
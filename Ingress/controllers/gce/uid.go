@@ -0,0 +1,69 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"k8s.io/kubernetes/pkg/api"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/util/uuid"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// uidConfigMapName is the well-known ConfigMap the UID used to tag every
+	// GCE resource is persisted in, so a controller restart recovers it
+	// instead of minting a new one (and orphaning everything tagged with
+	// the old one).
+	uidConfigMapName = "ingress-uid"
+	uidDataKey       = "uid"
+)
+
+// ensureUID returns the cluster UID persisted in uidConfigMapName, creating
+// the ConfigMap (seeded with clusterNameSeed if non-empty, otherwise a fresh
+// UUID) if it doesn't exist yet.
+func ensureUID(kubeClient *client.Client, clusterNameSeed string) (string, error) {
+	cm, err := kubeClient.ConfigMaps(api.NamespaceSystem).Get(uidConfigMapName)
+	if err == nil {
+		return cm.Data[uidDataKey], nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	uid := clusterNameSeed
+	if uid == "" {
+		uid = string(uuid.NewUUID())
+	}
+	glog.Infof("Creating %v/%v ConfigMap to persist cluster UID %v", api.NamespaceSystem, uidConfigMapName, uid)
+	cm, err = kubeClient.ConfigMaps(api.NamespaceSystem).Create(&api.ConfigMap{
+		ObjectMeta: api.ObjectMeta{Name: uidConfigMapName, Namespace: api.NamespaceSystem},
+		Data:       map[string]string{uidDataKey: uid},
+	})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// Lost a create race to another replica; defer to whatever it wrote.
+			cm, err = kubeClient.ConfigMaps(api.NamespaceSystem).Get(uidConfigMapName)
+			if err != nil {
+				return "", err
+			}
+			return cm.Data[uidDataKey], nil
+		}
+		return "", err
+	}
+	return cm.Data[uidDataKey], nil
+}
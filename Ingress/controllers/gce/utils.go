@@ -0,0 +1,234 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const (
+	// Prefix used for all GCE resources created by this controller.
+	k8sBePrefix           = "k8s-be"
+	k8sHcPrefix           = "k8s-hc"
+	k8sFwPrefix           = "k8s-fw"
+	k8sUmPrefix           = "k8s-um"
+	k8sTpPrefix           = "k8s-tp"
+	k8sIgPrefix           = "k8s-ig"
+	k8sCertPrefix         = "k8s-ssl"
+	k8sIPPrefix           = "k8s-fw-ip"
+	k8sFirewallRulePrefix = "k8s-fw-l7"
+
+	// nameLenLimit is the max length of a GCE resource name, as enforced by
+	// the compute API (63 chars, minus one for the trailing alphanumeric
+	// character truncate appends).
+	nameLenLimit = 62
+)
+
+// namerNameSeparator joins prefixes and the cluster/object identifiers that
+// make up a generated GCE resource name, eg: k8s-be-80-uid1.
+const namerNameSeparator = "-"
+
+// Namer is the naming convention used by all the pools. Every GCE resource
+// it names is tagged with a UID that's persisted across restarts (see
+// NewNamer), so a controller restart recovers the same names instead of
+// orphaning the previous run's resources.
+type Namer struct {
+	uid          string
+	firewallName string
+}
+
+// NewNamer creates a Namer tagging resources with the cluster UID persisted
+// in the kube-system ConfigMap (see ensureUID), creating it if this is the
+// cluster's first run. clusterNameSeed, if non-empty, becomes the UID when
+// the ConfigMap doesn't exist yet, so an admin upgrading from the old
+// --gce-cluster-name flag can carry its value over and avoid orphaning
+// existing resources. defaultFirewallName, if non-empty, names a firewall
+// rule managed out of band that FirewallRuleName should adopt instead of
+// generating one.
+func NewNamer(kubeClient *client.Client, clusterNameSeed, defaultFirewallName string) (*Namer, error) {
+	uid, err := ensureUID(kubeClient, clusterNameSeed)
+	if err != nil {
+		return nil, err
+	}
+	return &Namer{uid: uid, firewallName: defaultFirewallName}, nil
+}
+
+// SetUID sets the namer's cluster UID. Exposed so an admin can migrate
+// resources onto a new UID by rewriting the kube-system ConfigMap and
+// restarting the controller with it.
+func (n *Namer) SetUID(uid string) {
+	n.uid = uid
+}
+
+// UID returns the UID used to tag cluster wide, shared resources.
+func (n *Namer) UID() string {
+	return n.uid
+}
+
+// decorateName tags name with the namer's cluster UID.
+func (n *Namer) decorateName(name string) string {
+	if n.uid == "" {
+		return name
+	}
+	return truncate(fmt.Sprintf("%v%v%v", name, namerNameSeparator, n.uid))
+}
+
+// NameBelongsToCluster returns true if name is tagged with this namer's
+// cluster UID (or is the adopted, undecorated firewallName), so GC across
+// every pool can tell its own resources apart from another cluster's
+// same-named ones in a shared project.
+func (n *Namer) NameBelongsToCluster(name string) bool {
+	if name == n.firewallName {
+		return true
+	}
+	if n.uid == "" {
+		return true
+	}
+	return strings.HasSuffix(name, namerNameSeparator+n.uid)
+}
+
+// truncate truncates the given key to fit within GCE's resource name length
+// limit.
+func truncate(key string) string {
+	if len(key) > nameLenLimit {
+		// GCE requires names to end in an alphanumeric character.
+		return key[:nameLenLimit] + "0"
+	}
+	return key
+}
+
+// BeName constructs the name for a backend service, keyed by nodeport.
+func (n *Namer) BeName(port int64) string {
+	return n.decorateName(fmt.Sprintf("%v-%v", k8sBePrefix, port))
+}
+
+// HcName constructs the name for a http health check, keyed by nodeport.
+func (n *Namer) HcName(port int64) string {
+	return n.decorateName(fmt.Sprintf("%v-%v", k8sHcPrefix, port))
+}
+
+// IGName constructs the name for an Instance Group.
+func (n *Namer) IGName() string {
+	return n.decorateName(k8sIgPrefix)
+}
+
+// FrName constructs the name for a forwarding rule, keyed by the Ingress'
+// unique identifier (namespace/name).
+func (n *Namer) FrName(lbName string) string {
+	return n.decorateName(fmt.Sprintf("%v-%v", k8sFwPrefix, lbName))
+}
+
+// UMName constructs the name for a url map, keyed by the Ingress' unique
+// identifier.
+func (n *Namer) UMName(lbName string) string {
+	return n.decorateName(fmt.Sprintf("%v-%v", k8sUmPrefix, lbName))
+}
+
+// TPName constructs the name for a target http proxy, keyed by the Ingress'
+// unique identifier.
+func (n *Namer) TPName(lbName string) string {
+	return n.decorateName(fmt.Sprintf("%v-%v", k8sTpPrefix, lbName))
+}
+
+// TPSName constructs the name for a target https proxy, keyed by the
+// Ingress' unique identifier.
+func (n *Namer) TPSName(lbName string) string {
+	return n.decorateName(fmt.Sprintf("%vs-%v", k8sTpPrefix, lbName))
+}
+
+// FrsName constructs the name for the HTTPS forwarding rule on port 443,
+// keyed by the Ingress' unique identifier.
+func (n *Namer) FrsName(lbName string) string {
+	return n.decorateName(fmt.Sprintf("%vs-%v", k8sFwPrefix, lbName))
+}
+
+// CertName constructs the name for an SslCertificate. certHash identifies
+// the cert/key content so a rotation produces a new name the controller can
+// swap the target proxy to before GC'ing the stale one.
+func (n *Namer) CertName(lbName, certHash string) string {
+	return n.decorateName(fmt.Sprintf("%v-%v-%v", k8sCertPrefix, lbName, certHash))
+}
+
+// LBName returns a name derived from the given key, suitable for use as the
+// common suffix across all the resources of a single L7 loadbalancer.
+func LBName(key string) string {
+	return key
+}
+
+// IPName constructs the name for a global static IP, keyed by the Ingress'
+// unique identifier. It uses its own prefix, distinct from FrName's, so the
+// static IP and forwarding rule of the same lbName get different names.
+func (n *Namer) IPName(lbName string) string {
+	return n.decorateName(fmt.Sprintf("%v-%v", k8sIPPrefix, lbName))
+}
+
+// ParseName reverses UMName, returning the Ingress unique identifier a url
+// map name was generated from, or ok=false if name isn't one of this
+// cluster's url maps. Used by L7s.GC to discover loadbalancers orphaned by
+// an Ingress deleted while the controller wasn't running to see it.
+func (n *Namer) ParseName(name string) (lbName string, ok bool) {
+	s := strings.TrimPrefix(name, k8sUmPrefix+namerNameSeparator)
+	if s == name {
+		return "", false
+	}
+	if n.uid == "" {
+		return s, true
+	}
+	suffix := namerNameSeparator + n.uid
+	if !strings.HasSuffix(s, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(s, suffix), true
+}
+
+// FirewallRuleName constructs the name for the cluster's single firewall
+// rule, which unlike the other Name funcs isn't keyed by an Ingress or
+// NodePort: there's exactly one, opening GCE's health check ranges to every
+// node's NodePort range. If the namer was given a defaultFirewallName, that
+// rule is adopted by name instead, same as a user-provided static IP.
+func (n *Namer) FirewallRuleName() string {
+	if n.firewallName != "" {
+		return n.firewallName
+	}
+	return n.decorateName(k8sFirewallRulePrefix)
+}
+
+// ManagesFirewallRule returns false if the firewall rule returned by
+// FirewallRuleName was adopted from a pre-existing, admin-managed rule, and
+// so shouldn't be created, updated or deleted by the controller.
+func (n *Namer) ManagesFirewallRule() bool {
+	return n.firewallName == ""
+}
+
+// IsHTTPErrorCode returns true if the given error is a googleapi.Error
+// carrying the given HTTP status code, eg a 404 or a 409 Conflict returned
+// when a resource we're trying to create already exists.
+func IsHTTPErrorCode(err error, code int) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	return ok && apiErr.Code == code
+}
+
+// isNotFoundErr is a convenience wrapper around IsHTTPErrorCode for the
+// common "doesn't exist yet" case.
+func isNotFoundErr(err error) bool {
+	return IsHTTPErrorCode(err, http.StatusNotFound)
+}
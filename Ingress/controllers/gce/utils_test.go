@@ -0,0 +1,63 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestNamerIPNameDistinctFromFrName(t *testing.T) {
+	namer := &Namer{uid: "uid1"}
+	if ip, fr := namer.IPName("foo"), namer.FrName("foo"); ip == fr {
+		t.Errorf("IPName and FrName collided: both returned %v", ip)
+	}
+}
+
+func TestNamerDecorateName(t *testing.T) {
+	namer := &Namer{uid: "uid1"}
+	name := namer.BeName(80)
+	if name != "k8s-be-80-uid1" {
+		t.Errorf("BeName(80) = %v, want k8s-be-80-uid1", name)
+	}
+	namer.uid = ""
+	if name := namer.BeName(80); name != "k8s-be-80" {
+		t.Errorf("BeName(80) with no uid = %v, want k8s-be-80", name)
+	}
+}
+
+func TestNamerNameBelongsToCluster(t *testing.T) {
+	namer := &Namer{uid: "uid1"}
+	if !namer.NameBelongsToCluster(namer.BeName(80)) {
+		t.Errorf("expected %v to belong to the cluster", namer.BeName(80))
+	}
+	if namer.NameBelongsToCluster("k8s-be-80-uid2") {
+		t.Errorf("expected k8s-be-80-uid2 not to belong to the cluster")
+	}
+}
+
+func TestNamerParseName(t *testing.T) {
+	namer := &Namer{uid: "uid1"}
+	umName := namer.UMName("ns-ing")
+	lbName, ok := namer.ParseName(umName)
+	if !ok || lbName != "ns-ing" {
+		t.Errorf("ParseName(%v) = (%v, %v), want (ns-ing, true)", umName, lbName, ok)
+	}
+	if _, ok := namer.ParseName("k8s-be-80-uid1"); ok {
+		t.Errorf("expected ParseName to reject a name from a different prefix")
+	}
+	if _, ok := namer.ParseName("k8s-um-ns-ing-uid2"); ok {
+		t.Errorf("expected ParseName to reject a url map tagged with another cluster's uid")
+	}
+}